@@ -0,0 +1,252 @@
+package undeck
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// BinaryVersion of the binary encoding written by MarshalBinary, it is the first byte of the encoding
+const BinaryVersion = 1
+
+const (
+	flagShuffled = 1 << iota
+	flagHidden
+	flagForked // the id of the parent deck follows the id
+)
+
+var (
+	// ErrInvalidBinary indicates that a binary encoded deck is truncated or malformed
+	ErrInvalidBinary = errors.New("binary deck is not valid")
+
+	// ErrCardNotInSystem indicates that a deck holds a card which is not part of its card system
+	ErrCardNotInSystem = errors.New("card is not part of the card system")
+
+	// ErrSystemTooLarge indicates that a card system has too many cards to be binary encoded one byte per card
+	ErrSystemTooLarge = errors.New("card system has more than 256 cards")
+)
+
+// MarshalBinary encodes the deck compactly, each card is a single byte: its index in the full set of its card system.
+// The header holds the encoding version, flags, the card system, the deck version and timestamps. The shuffler is not kept
+func (d Deck) MarshalBinary() ([]byte, error) {
+	if d.System == "" {
+		d.System = DefaultSystem
+	}
+
+	var system, err = System(d.System)
+	if err != nil {
+		return nil, err
+	}
+
+	if system.index == nil {
+		return nil, ErrSystemTooLarge
+	}
+
+	var (
+		flags byte
+		b     = make([]byte, 0, 32+len(d.ID)+len(d.ParentID)+len(d.System)+len(d.cards)+len(d.drawn))
+	)
+
+	if d.IsShuffled {
+		flags |= flagShuffled
+	}
+
+	if d.Hidden {
+		flags |= flagHidden
+	}
+
+	if d.ParentID != "" {
+		flags |= flagForked
+	}
+
+	b = append(b, BinaryVersion, flags)
+	b = appendString(b, d.System)
+	b = appendString(b, d.ID)
+
+	if d.ParentID != "" {
+		b = appendString(b, d.ParentID)
+	}
+	b = binary.AppendUvarint(b, uint64(d.Version))
+	b = binary.AppendVarint(b, unixNano(d.CreatedAt))
+	b = binary.AppendVarint(b, unixNano(d.AccessedAt))
+
+	for _, cards := range [][]Card{d.cards, d.drawn} {
+		b = binary.AppendUvarint(b, uint64(len(cards)))
+
+		for _, c := range cards {
+			var i, ok = system.index[c.String()]
+			if !ok {
+				return nil, ErrCardNotInSystem
+			}
+
+			b = append(b, i)
+		}
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary rebuilds a deck encoded by MarshalBinary
+func (d *Deck) UnmarshalBinary(b []byte) error {
+	var (
+		deck Deck
+		r    = binaryReader{b: b}
+	)
+
+	if r.byte() != BinaryVersion {
+		return ErrInvalidBinary
+	}
+
+	var flags = r.byte()
+
+	deck.IsShuffled = flags&flagShuffled != 0
+	deck.Hidden = flags&flagHidden != 0
+	deck.System = r.string()
+	deck.ID = r.string()
+
+	if flags&flagForked != 0 {
+		deck.ParentID = r.string()
+	}
+	deck.Version = int(r.uvarint())
+	deck.CreatedAt = fromUnixNano(r.varint())
+	deck.AccessedAt = fromUnixNano(r.varint())
+
+	if r.err != nil {
+		return r.err
+	}
+
+	var system, err = System(deck.System)
+	if err != nil {
+		return err
+	}
+
+	for _, cards := range []*[]Card{&deck.cards, &deck.drawn} {
+		var n = r.uvarint()
+
+		if r.err == nil && n > uint64(len(r.b)) {
+			return ErrInvalidBinary
+		}
+
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			var j = int(r.byte())
+			if j >= len(system.all) {
+				return ErrInvalidBinary
+			}
+
+			*cards = append(*cards, system.all[j])
+		}
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	*d = deck
+
+	return nil
+}
+
+// UnmarshalDeck rebuilds a deck from either its binary or its JSON encoding, e.g. when stored decks may predate the binary encoding
+func UnmarshalDeck(b []byte) (Deck, error) {
+	var (
+		d   Deck
+		err error
+	)
+
+	if len(b) > 0 && b[0] == BinaryVersion {
+		err = d.UnmarshalBinary(b)
+	} else {
+		err = json.Unmarshal(b, &d)
+	}
+
+	return d, err
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, n).UTC()
+}
+
+// binaryReader reads a binary deck, the first error is kept and later reads return zero values
+type binaryReader struct {
+	b   []byte
+	err error
+}
+
+func (r *binaryReader) byte() byte {
+	if r.err != nil || len(r.b) == 0 {
+		r.err = ErrInvalidBinary
+		return 0
+	}
+
+	var c = r.b[0]
+	r.b = r.b[1:]
+
+	return c
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	var v, n = binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = ErrInvalidBinary
+		return 0
+	}
+
+	r.b = r.b[n:]
+
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	var v, n = binary.Varint(r.b)
+	if n <= 0 {
+		r.err = ErrInvalidBinary
+		return 0
+	}
+
+	r.b = r.b[n:]
+
+	return v
+}
+
+func (r *binaryReader) string() string {
+	var n = r.uvarint()
+
+	if r.err != nil {
+		return ""
+	}
+
+	if n > uint64(len(r.b)) {
+		r.err = ErrInvalidBinary
+		return ""
+	}
+
+	var s = string(r.b[:n])
+	r.b = r.b[n:]
+
+	return s
+}
@@ -0,0 +1,115 @@
+package undeck_test
+
+import (
+	"encoding/json"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"testing"
+	"time"
+)
+
+func testDeck() undeck.Deck {
+	var d = undeck.Deck{
+		ID:         "0b5b1d1e-3c8a-4e5e-9d2b-7a4f7b9c6f01",
+		ParentID:   "6f1c2a4e-8b7d-4c3e-a1f0-2d9e8b7c6a50",
+		System:     french.System,
+		Version:    12,
+		CreatedAt:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		AccessedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}.Add(french.All()...).Shuffle()
+
+	d, _, _ = d.Draw(5)
+
+	return d
+}
+
+func TestDeck_MarshalBinary(t *testing.T) {
+	var d = testDeck()
+
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	var got undeck.Deck
+	if err = got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	internal.AssertDecksEqual(t, d, got)
+	internal.AssertCardSlicesEqual(t, d.Drawn(), got.Drawn())
+
+	if got.System != d.System || !got.CreatedAt.Equal(d.CreatedAt) || !got.AccessedAt.Equal(d.AccessedAt) {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, d)
+	}
+
+	for i := 0; i < len(b); i++ {
+		if err = got.UnmarshalBinary(b[:i]); err != undeck.ErrInvalidBinary {
+			t.Errorf("UnmarshalBinary() of %d bytes error = %v, want %v", i, err, undeck.ErrInvalidBinary)
+		}
+	}
+}
+
+func TestUnmarshalDeck(t *testing.T) {
+	var d = testDeck()
+
+	for name, marshal := range map[string]func() ([]byte, error){
+		"binary": d.MarshalBinary,
+		"json":   d.MarshalJSON,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var b, err = marshal()
+			if err != nil {
+				t.Fatalf("marshal error = %v", err)
+			}
+
+			got, err := undeck.UnmarshalDeck(b)
+			if err != nil {
+				t.Fatalf("UnmarshalDeck() error = %v", err)
+			}
+
+			internal.AssertDecksEqual(t, d, got)
+		})
+	}
+}
+
+func BenchmarkDeck_MarshalBinary(b *testing.B) {
+	var d = testDeck()
+
+	for i := 0; i < b.N; i++ {
+		var v, _ = d.MarshalBinary()
+		b.SetBytes(int64(len(v)))
+	}
+}
+
+func BenchmarkDeck_MarshalJSON(b *testing.B) {
+	var d = testDeck()
+
+	for i := 0; i < b.N; i++ {
+		var v, _ = json.Marshal(d)
+		b.SetBytes(int64(len(v)))
+	}
+}
+
+func BenchmarkDeck_UnmarshalBinary(b *testing.B) {
+	var v, _ = testDeck().MarshalBinary()
+
+	b.SetBytes(int64(len(v)))
+
+	for i := 0; i < b.N; i++ {
+		var d undeck.Deck
+		d.UnmarshalBinary(v)
+	}
+}
+
+func BenchmarkDeck_UnmarshalJSON(b *testing.B) {
+	var v, _ = json.Marshal(testDeck())
+
+	b.SetBytes(int64(len(v)))
+
+	for i := 0; i < b.N; i++ {
+		var d undeck.Deck
+		json.Unmarshal(v, &d)
+	}
+}
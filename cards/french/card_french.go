@@ -31,11 +31,20 @@ const (
 	King
 )
 
+// System is the name french cards are registered under for deserializing decks
+const System = "french"
+
 func init() {
 	var (
 		_ undeck.Rank = rank(1)
 		_ undeck.Suit = suit('S')
 	)
+
+	undeck.RegisterSystem(undeck.CardSystem{
+		Name:   System,
+		Decode: FromString,
+		All:    All,
+	})
 }
 
 // rank of a card 1-13
@@ -0,0 +1,80 @@
+package french
+
+import (
+	"go.fluxy.net/undeck"
+	"strings"
+)
+
+// rankOf a card, falling back to its short code when the rank does not come from this package
+func rankOf(r undeck.Rank) rank {
+	if v, ok := r.(rank); ok {
+		return v
+	}
+
+	var v, _ = rankFromString(r.Short())
+
+	return v
+}
+
+// suitOf a card, falling back to its short code when the suit does not come from this package
+func suitOf(s undeck.Suit) suit {
+	if v, ok := s.(suit); ok {
+		return v
+	}
+
+	var v, _ = suitFromString(s.Short())
+
+	return v
+}
+
+// SuitRankOrder sorts by suit (Spade, Diamond, Club, Heart) then by rank from Ace to King
+func SuitRankOrder(a, b undeck.Card) bool {
+	var sa, sb = suitOf(a.Suit), suitOf(b.Suit)
+
+	if sa != sb {
+		return sa < sb
+	}
+
+	return rankOf(a.Rank) < rankOf(b.Rank)
+}
+
+// RankSuitOrder sorts by rank from Ace to King then by suit (Spade, Diamond, Club, Heart)
+func RankSuitOrder(a, b undeck.Card) bool {
+	var ra, rb = rankOf(a.Rank), rankOf(b.Rank)
+
+	if ra != rb {
+		return ra < rb
+	}
+
+	return suitOf(a.Suit) < suitOf(b.Suit)
+}
+
+// NewDeckOrder sorts the way a new deck comes out of its box:
+// Ace to King of Spades and Diamonds followed by King to Ace of Clubs and Hearts
+func NewDeckOrder(a, b undeck.Card) bool {
+	var sa, sb = suitOf(a.Suit), suitOf(b.Suit)
+
+	if sa != sb {
+		return sa < sb
+	}
+
+	if sa == Club || sa == Heart {
+		return rankOf(a.Rank) > rankOf(b.Rank)
+	}
+
+	return rankOf(a.Rank) < rankOf(b.Rank)
+}
+
+// OrderFromString returns an order from its name, e.g. "new", "suit,rank" or "rank,suit"
+func OrderFromString(s string) (undeck.Order, error) {
+	switch strings.ToLower(strings.ReplaceAll(s, " ", "")) {
+	case "new":
+		return NewDeckOrder, nil
+	case "suit", "suit,rank":
+		return SuitRankOrder, nil
+	case "rank", "rank,suit":
+		return RankSuitOrder, nil
+	}
+
+	return nil, undeck.ErrInvalidOrder
+}
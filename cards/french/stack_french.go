@@ -0,0 +1,100 @@
+package french
+
+import (
+	"go.fluxy.net/undeck"
+	"sort"
+	"strings"
+)
+
+// stacks are memorised deck orders used by magicians, each one is a complete ordering of All()
+var stacks = map[string]func() []undeck.Card{
+	"mnemonica": fixedStack(
+		"4C,2H,7D,3C,4H,6D,AS,5H,9S,2S,QH,3D,QC,8H,6S,5S,9H,KC,2D,JH,3S,8S,6H,TC,5D,KD," +
+			"2C,3H,8D,5C,KS,JD,8C,TS,KH,JC,7S,TH,AD,4S,7H,4D,AC,9C,JS,QD,7C,QS,TD,6C,AH,9D",
+	),
+	"aronson": fixedStack(
+		"JS,KC,5C,2H,9S,AS,3H,6C,8D,AC,TS,5H,2D,KD,7D,8C,3S,AD,7S,5S,QD,AH,8S,3D,7H,QH," +
+			"5D,7C,4H,KH,4D,TD,JC,JH,TC,JD,4S,TH,6H,3C,2S,9H,KS,6S,4C,8H,9C,QS,6D,QC,2C,9D",
+	),
+	"si-stebbins": siStebbins,
+}
+
+// fixedStack returns the cards from a comma separated list of codes
+func fixedStack(codes string) func() []undeck.Card {
+	return func() []undeck.Card {
+		var c []undeck.Card
+
+		for _, code := range strings.Split(codes, ",") {
+			var card, err = FromString(code)
+			if err != nil {
+				panic("french: invalid card in stack: " + code)
+			}
+
+			c = append(c, card)
+		}
+
+		return c
+	}
+}
+
+// siStebbins starts from the Ace of Clubs, each next card is 3 ranks higher in the suit order Club, Heart, Spade, Diamond
+func siStebbins() []undeck.Card {
+	var (
+		c []undeck.Card
+
+		r     = Ace
+		suits = []suit{Club, Heart, Spade, Diamond}
+	)
+
+	for i := 0; i < 52; i++ {
+		c = append(c, undeck.Card{
+			Rank: r,
+			Suit: suits[i%len(suits)],
+		})
+
+		r = (r+2)%13 + 1
+	}
+
+	return c
+}
+
+// StackNames returns the names of all known stacks in alphabetical order
+func StackNames() []string {
+	var names []string
+
+	for name := range stacks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Stack returns the cards of a stack by name e.g. mnemonica, aronson or si-stebbins
+func Stack(name string) ([]undeck.Card, error) {
+	var f, ok = stacks[strings.ToLower(name)]
+
+	if !ok {
+		return nil, undeck.ErrInvalidStack
+	}
+
+	return f(), nil
+}
+
+// StackPosition returns the position of a card in a stack, the top card being at position 1
+func StackPosition(name string, card undeck.Card) (int, error) {
+	var c, err = Stack(name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range c {
+		if c[i].String() == card.String() {
+			return i + 1, nil
+		}
+	}
+
+	return 0, undeck.ErrCardNotInStack
+}
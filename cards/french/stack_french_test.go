@@ -0,0 +1,75 @@
+package french
+
+import (
+	"go.fluxy.net/undeck"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func codes(c []undeck.Card) []string {
+	var s []string
+
+	for i := range c {
+		s = append(s, c[i].String())
+	}
+
+	return s
+}
+
+func TestStack(t *testing.T) {
+	var all = codes(All())
+	sort.Strings(all)
+
+	for _, name := range StackNames() {
+		t.Run(name, func(t *testing.T) {
+			var c, err = Stack(name)
+			if err != nil {
+				t.Fatalf("Stack() error = %v", err)
+			}
+
+			var got = codes(c)
+			sort.Strings(got)
+
+			if strings.Join(got, ",") != strings.Join(all, ",") {
+				t.Errorf("stack is not a complete ordering of All()\ngot = %v", got)
+			}
+		})
+	}
+
+	if _, err := Stack("joker"); err != undeck.ErrInvalidStack {
+		t.Errorf("Stack() unknown stack error = %v, want %v", err, undeck.ErrInvalidStack)
+	}
+}
+
+func TestStackPosition(t *testing.T) {
+	tests := []struct {
+		stack string
+		code  string
+		want  int
+	}{
+		{stack: "mnemonica", code: "4C", want: 1},
+		{stack: "mnemonica", code: "AS", want: 7},
+		{stack: "Mnemonica", code: "9D", want: 52},
+		{stack: "aronson", code: "JS", want: 1},
+		{stack: "aronson", code: "9D", want: 52},
+		{stack: "si-stebbins", code: "AC", want: 1},
+		{stack: "si-stebbins", code: "4H", want: 2},
+		{stack: "si-stebbins", code: "JD", want: 52},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stack+" "+tt.code, func(t *testing.T) {
+			var card, _ = FromString(tt.code)
+
+			got, err := StackPosition(tt.stack, card)
+			if err != nil {
+				t.Fatalf("StackPosition() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("StackPosition() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package lehmer encodes the order of cards as a compact code by ranking the permutation against the full set of a card system
+package lehmer
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"go.fluxy.net/undeck"
+	"math/big"
+)
+
+var (
+	// ErrInvalidCode indicates that a code could not be decoded
+	ErrInvalidCode = errors.New("code is not valid")
+
+	// ErrNotPermutation indicates that the cards are not distinct members of the full set
+	ErrNotPermutation = errors.New("cards are not a permutation of the full set")
+)
+
+// Encode the order of cards as a url safe code. full is the complete set of the card system the cards belong to,
+// cards may be any number of distinct cards from full
+func Encode(full []undeck.Card, cards []undeck.Card) (string, error) {
+	var (
+		rank  = new(big.Int)
+		avail = codes(full)
+		head  = make([]byte, binary.MaxVarintLen64)
+	)
+
+	if len(cards) > len(full) {
+		return "", ErrNotPermutation
+	}
+
+	for i := range cards {
+		var j = indexOf(avail, cards[i].String())
+		if j == -1 {
+			return "", ErrNotPermutation
+		}
+
+		rank.Mul(rank, big.NewInt(int64(len(avail))))
+		rank.Add(rank, big.NewInt(int64(j)))
+
+		avail = append(avail[:j], avail[j+1:]...)
+	}
+
+	var n = binary.PutUvarint(head, uint64(len(cards)))
+
+	return base64.RawURLEncoding.EncodeToString(append(head[:n], rank.Bytes()...)), nil
+}
+
+// Decode a code produced by Encode back into cards, full must be the same set the code was encoded with
+func Decode(full []undeck.Card, code string) ([]undeck.Card, error) {
+	var b, err = base64.RawURLEncoding.DecodeString(code)
+	if err != nil {
+		return nil, ErrInvalidCode
+	}
+
+	var k, n = binary.Uvarint(b)
+	if n <= 0 || k > uint64(len(full)) {
+		return nil, ErrInvalidCode
+	}
+
+	var (
+		rank   = new(big.Int).SetBytes(b[n:])
+		digits = make([]int, k)
+		radix  = new(big.Int)
+		digit  = new(big.Int)
+	)
+
+	for i := int(k) - 1; i >= 0; i-- {
+		radix.SetInt64(int64(len(full) - i))
+		rank.DivMod(rank, radix, digit)
+		digits[i] = int(digit.Int64())
+	}
+
+	if rank.Sign() != 0 {
+		return nil, ErrInvalidCode
+	}
+
+	var (
+		cards []undeck.Card
+		avail = make([]undeck.Card, len(full))
+	)
+
+	copy(avail, full)
+
+	for _, j := range digits {
+		cards = append(cards, avail[j].Duplicate())
+		avail = append(avail[:j], avail[j+1:]...)
+	}
+
+	return cards, nil
+}
+
+func codes(cards []undeck.Card) []string {
+	var c = make([]string, len(cards))
+
+	for i := range cards {
+		c[i] = cards[i].String()
+	}
+
+	return c
+}
+
+func indexOf(codes []string, code string) int {
+	for i := range codes {
+		if codes[i] == code {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,109 @@
+package lehmer
+
+import (
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	var reversed []undeck.Card
+
+	for _, c := range french.All() {
+		reversed = append([]undeck.Card{c}, reversed...)
+	}
+
+	tests := []struct {
+		name  string
+		cards []undeck.Card
+		want  string
+	}{
+		{
+			name:  "empty",
+			cards: nil,
+			want:  "AA",
+		},
+		{
+			name:  "first cards in order",
+			cards: cards.MustString(french.FromString, "AS,2S,3S,4S"),
+			want:  "BA",
+		},
+		{
+			name:  "full set in order",
+			cards: french.All(),
+			want:  "NA",
+		},
+		{
+			name:  "full set reversed",
+			cards: reversed,
+		},
+		{
+			name:  "partial shuffled",
+			cards: cards.MustString(french.FromString, "KH,2C,TD,AS,9S"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(french.All(), tt.cards)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			if tt.want != "" && code != tt.want {
+				t.Errorf("Encode() = %s, want %s", code, tt.want)
+			}
+
+			if len(code) > 41 {
+				t.Errorf("Encode() code too long: %d characters", len(code))
+			}
+
+			got, err := Decode(french.All(), code)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			internal.AssertCardSlicesEqual(t, tt.cards, got)
+		})
+	}
+}
+
+func TestEncode_NotPermutation(t *testing.T) {
+	tests := []struct {
+		name  string
+		cards []undeck.Card
+	}{
+		{
+			name:  "duplicate card",
+			cards: cards.MustString(french.FromString, "AS,KH,AS"),
+		},
+		{
+			name:  "card not in set",
+			cards: cards.MustString(french.FromString, "AS,KH"),
+		},
+	}
+
+	var full = cards.MustString(french.FromString, "AS,2S,3S")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Encode(full, tt.cards); err != ErrNotPermutation {
+				t.Errorf("Encode() error = %v, want %v", err, ErrNotPermutation)
+			}
+		})
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	var full = cards.MustString(french.FromString, "AS,2S,3S")
+
+	for _, code := range []string{"", "!!", "BA", "AwY"} {
+		t.Run(code, func(t *testing.T) {
+			if _, err := Decode(full, code); err != ErrInvalidCode {
+				t.Errorf("Decode() error = %v, want %v", err, ErrInvalidCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.fluxy.net/undeck/repo/bolt"
+	"path/filepath"
+)
+
+// Maintenance of the repos storing decks on disk
+type Maintenance struct {
+	// DataDir is where decks are stored
+	DataDir string
+}
+
+func (m *Maintenance) compactCmd(cmd *cobra.Command, args []string) error {
+	var path = filepath.Join(m.DataDir, "undeck.bolt")
+
+	if err := bolt.Compact(path); err != nil {
+		return err
+	}
+
+	fmt.Println("compacted", path)
+
+	return nil
+}
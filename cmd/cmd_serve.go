@@ -1,33 +1,250 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"github.com/go-chi/chi/v5"
 	"github.com/spf13/cobra"
-	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/cache"
+	"go.fluxy.net/undeck/repo/history"
+	"go.fluxy.net/undeck/repo/tenant"
+	"go.fluxy.net/undeck/repo/undo"
+	"go.fluxy.net/undeck/web"
 	wchi "go.fluxy.net/undeck/web/chi"
 	"go.fluxy.net/undeck/web/draw"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"time"
 )
 
 // Server over http
 type Server struct {
+	Storage
+
 	Port string
+
+	// Timeout of each request, requests taking longer get 504 Gateway Timeout. Zero for none
+	Timeout time.Duration
+
+	// History keeps the events of every deck in memory so that they can be replayed, until the server stops
+	History bool
+
+	// HistoryRetention after which the history of decks left unchanged is dropped, zero to keep it forever
+	HistoryRetention time.Duration
+
+	// UndoDepth is how many changes to each deck can be undone, zero to disable undo
+	UndoDepth int
+
+	// CacheSize is how many recently used decks are kept in memory in front of the repo, zero to disable the cache
+	CacheSize int
+
+	// CacheMaxAge after which cached decks are reloaded from the repo, zero to keep them until evicted
+	CacheMaxAge time.Duration
+
+	// APIKeys is the path of a file mapping api keys to tenants, each tenant only reaching its own decks
+	APIKeys string
+
+	// TenantHeader takes the tenant of requests from the X-Tenant header set by a trusted proxy
+	TenantHeader bool
+
+	// TenantQuota is how many decks each tenant may keep, zero for no limit
+	TenantQuota int
+
+	// AdminAddr is the host:port of the listener for operators, listing every deck and serving the metrics at
+	// /debug/vars. Empty for none, it must not be reachable by clients
+	AdminAddr string
+}
+
+// changer is a repo telling when decks are changed by other instances, e.g. redis
+type changer interface {
+	Changes(ctx context.Context, fn func(id string)) error
 }
 
-func (s Server) serveCmd(cmd *cobra.Command, args []string) {
+// tenants of requests and the quotas of their decks, no getter if the server is not shared by tenants
+func (s *Server) tenants() (web.TenantGetter, []tenant.Option, error) {
+	var opts = []tenant.Option{tenant.WithQuota(s.TenantQuota)}
+
+	switch {
+	case s.APIKeys != "" && s.TenantHeader:
+		return nil, nil, errors.New("tenants are either taken from api keys or from a header, not both")
+	case s.TenantHeader:
+		return web.HeaderTenantGetter, opts, nil
+	case s.APIKeys == "":
+		return nil, nil, nil
+	}
+
+	var keys, err = loadTenantKeys(s.APIKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for t, q := range keys.quotas {
+		opts = append(opts, tenant.WithTenantQuota(t, q))
+	}
+
+	return web.APIKeyTenantGetter(keys.tenants), opts, nil
+}
+
+func (s *Server) serveCmd(cmd *cobra.Command, args []string) {
+	var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var repo, err = s.open(ctx)
+	if err != nil {
+		log.Println("failed to open repo: ", err.Error())
+		return
+	}
+
+	defer closeRepo(repo)
+
+	// the cache goes right in front of the storage so that history and undo read through it
+	if s.CacheSize > 0 {
+		var ch, ok = repo.(changer)
+
+		if !ok && s.shared() && s.CacheMaxAge <= 0 {
+			log.Println("--cache-size needs --cache-max-age with the " + s.Repo + " repo, the decks changed by other processes would stay stale forever")
+			return
+		}
+
+		if s.TTL > 0 && s.CacheMaxAge >= s.TTL {
+			log.Println("--cache-max-age must be below --ttl, cached decks would be served after they expired")
+			return
+		}
+
+		var c = cache.New(repo, s.CacheSize, cache.WithMaxAge(s.CacheMaxAge), cache.WithTTL(s.TTL))
+
+		if ok {
+			go func() {
+				if err := ch.Changes(ctx, func(id string) { c.Invalidate(id) }); err != nil {
+					log.Println("cache invalidation stopped: ", err.Error())
+				}
+			}()
+		}
+
+		expvar.Publish("cache", expvar.Func(func() interface{} { return c.Stats() }))
+		repo = c
+	}
+
+	var (
+		tenants web.TenantGetter
+		opts    []tenant.Option
+
+		// operators list the decks of every tenant
+		admin = draw.New(repo, wchi.IDGetter)
+	)
+
+	if tenants, opts, err = s.tenants(); err != nil {
+		log.Println("failed to set up tenants: ", err.Error())
+		return
+	}
+
+	// history and undo go in front of the tenants so that they are kept per tenant, see repo.Key
+	if tenants != nil {
+		repo = tenant.New(repo, opts...)
+	}
+
+	if s.History {
+		var h = history.New(repo, history.WithRetention(s.HistoryRetention))
+
+		// empty logs left by failed changes are pruned even when histories are kept forever
+		var interval = time.Minute
+		if r := s.HistoryRetention / 10; r > 0 && r < interval {
+			interval = r
+		}
+
+		go h.Janitor(ctx, interval)
+		repo = h
+	}
+
+	// undo wraps history so that undone changes are recorded too
+	if s.UndoDepth > 0 {
+		var u = undo.New(repo, s.UndoDepth)
+
+		// drops the stacks of decks which were deleted elsewhere or expired
+		go u.Janitor(ctx, time.Minute)
+		repo = u
+	}
+
 	var (
-		repo  = memory.New()
 		drawg = draw.New(repo, wchi.IDGetter)
 		mux   = chi.NewMux()
 	)
 
+	mux.Use(web.Timeout(s.Timeout))
+
 	mux.Route("/draw", func(r chi.Router) {
+		if tenants != nil {
+			r.Use(web.Tenants(tenants))
+		}
+
 		r.Post("/deck", drawg.Create)
+
+		// ids are the only access control without tenants, so they are only listed to tenants for their own decks
+		if tenants != nil {
+			r.Get("/deck", drawg.List)
+		}
+
 		r.Get("/deck/{id}", drawg.Open)
 		r.Patch("/deck/{id}", drawg.Draw)
+		r.Delete("/deck/{id}", drawg.Delete)
+		r.Post("/deck/{id}/sort", drawg.Sort)
+		r.Post("/deck/{id}/fork", drawg.Fork)
+		r.Get("/deck/{id}/code", drawg.Code)
+		r.Get("/deck/{id}/odds", drawg.Odds)
+		r.Get("/deck/{id}/summary", drawg.Summary)
+		r.Get("/deck/{id}/history", drawg.History)
+		r.Post("/deck/{id}/undo", drawg.Undo)
+		r.Post("/deck/{id}/redo", drawg.Redo)
+		r.Get("/stack", drawg.Stack)
 	})
 
+	if s.AdminAddr != "" {
+		var adminMux = chi.NewMux()
+
+		adminMux.Use(web.Timeout(s.Timeout))
+		adminMux.Get("/draw/deck", admin.List)
+		adminMux.Handle("/debug/vars", expvar.Handler())
+
+		go func() {
+			log.Println("Starting admin server on http://" + s.AdminAddr)
+			if err := s.listen(ctx, s.AdminAddr, adminMux); err != nil {
+				log.Println("admin server stopped: ", err.Error())
+			}
+		}()
+	}
+
 	log.Println("Starting server on http://127.0.0.1:" + s.Port)
-	http.ListenAndServe(":"+s.Port, mux)
+	if err := s.listen(ctx, ":"+s.Port, mux); err != nil {
+		log.Println("server stopped: ", err.Error())
+	}
+}
+
+// listen on addr until ctx is done
+func (s *Server) listen(ctx context.Context, addr string, h http.Handler) error {
+	var server = &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	// leave handlers time to write the timeout response before the connection is cut
+	if s.Timeout > 0 {
+		server.ReadTimeout = s.Timeout
+		server.WriteTimeout = 2 * s.Timeout
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/tenant"
+	"io"
+	"os"
+	"time"
+)
+
+// maxLine is the longest line of a deck accepted by import
+const maxLine = 1 << 20
+
+var (
+	// errNoID means an imported deck has no id to be stored under
+	errNoID = errors.New("deck has no id")
+
+	// errMemoryRepo means decks would be transferred from or to a memory repo without its write-ahead log, which only
+	// lives as long as the command
+	errMemoryRepo = errors.New("the memory repo starts empty and is gone when the command ends without --wal, choose another --repo")
+)
+
+// Transfer of decks between a repo and jsonl streams, one deck as JSON per line
+type Transfer struct {
+	Storage
+
+	// CreatedBefore only transfers decks created before this time, RFC 3339
+	CreatedBefore string
+
+	// MinRemaining only transfers decks with at least this many cards left
+	MinRemaining int
+
+	// MaxRemaining only transfers decks with at most this many cards left
+	MaxRemaining int
+
+	// DryRun validates the decks to import without saving them
+	DryRun bool
+
+	// Replace decks already stored with the imported ones instead of skipping them
+	Replace bool
+
+	// Tenant only transfers the decks of this tenant, with their ids as the tenant sees them
+	Tenant string
+}
+
+// importStats counts what happened to the decks read by import
+type importStats struct {
+	Imported int
+	Skipped  int
+	Invalid  int
+}
+
+// filter of the decks to transfer, the remaining bounds only apply when their flags were set
+func (t *Transfer) filter(cmd *cobra.Command) (undeck.ListFilter, error) {
+	var f undeck.ListFilter
+
+	if t.CreatedBefore != "" {
+		var at, err = time.Parse(time.RFC3339, t.CreatedBefore)
+		if err != nil {
+			return f, fmt.Errorf("created before: %w", err)
+		}
+
+		f.CreatedBefore = at
+	}
+
+	if cmd.Flags().Changed("min-remaining") {
+		f.MinRemaining = &t.MinRemaining
+	}
+
+	if cmd.Flags().Changed("max-remaining") {
+		f.MaxRemaining = &t.MaxRemaining
+	}
+
+	return f, nil
+}
+
+// open the repo to transfer decks from or to, refusing a memory repo which holds no decks.
+// The memory repo of a stopped server is opened with its write-ahead log
+func (t *Transfer) open(ctx context.Context) (undeck.Repo, error) {
+	if (t.Repo == "" || t.Repo == "memory") && !t.WAL {
+		return nil, errMemoryRepo
+	}
+
+	return t.Storage.open(ctx)
+}
+
+// scope r to the tenant of the transfer, if any
+func (t *Transfer) scope(ctx context.Context, r undeck.Repo) (context.Context, undeck.Repo) {
+	if t.Tenant == "" {
+		return ctx, r
+	}
+
+	return repo.WithTenant(ctx, t.Tenant), tenant.New(r)
+}
+
+func (t *Transfer) exportCmd(cmd *cobra.Command, args []string) error {
+	var ctx = cmd.Context()
+
+	filter, err := t.filter(cmd)
+	if err != nil {
+		return err
+	}
+
+	r, err := t.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer closeRepo(r)
+
+	ctx, scoped := t.scope(ctx, r)
+
+	var w = bufio.NewWriter(os.Stdout)
+
+	n, err := exportDecks(ctx, scoped, filter, w)
+	if err != nil {
+		return err
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "exported", n, "decks")
+
+	return nil
+}
+
+func (t *Transfer) importCmd(cmd *cobra.Command, args []string) error {
+	var ctx = cmd.Context()
+
+	filter, err := t.filter(cmd)
+	if err != nil {
+		return err
+	}
+
+	r, err := t.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer closeRepo(r)
+
+	ctx, scoped := t.scope(ctx, r)
+
+	stats, err := importDecks(ctx, scoped, filter, os.Stdin, os.Stderr, t.DryRun, t.Replace)
+
+	if t.DryRun {
+		fmt.Fprintf(os.Stderr, "dry run: %d decks would be imported, %d skipped, %d invalid\n", stats.Imported, stats.Skipped, stats.Invalid)
+	} else {
+		fmt.Fprintf(os.Stderr, "imported %d decks, %d skipped\n", stats.Imported, stats.Skipped)
+	}
+
+	return err
+}
+
+// exportDecks writes the decks of r matching filter to w a page at a time, returning how many were written
+func exportDecks(ctx context.Context, r undeck.Repo, filter undeck.ListFilter, w io.Writer) (int, error) {
+	var (
+		n   int
+		enc = json.NewEncoder(w)
+	)
+
+	filter.Limit = undeck.MaxListLimit
+
+	for {
+		var page, err = r.List(ctx, filter)
+		if err != nil {
+			return n, err
+		}
+
+		for _, d := range page.Decks {
+			if err = enc.Encode(d); err != nil {
+				return n, err
+			}
+
+			n++
+		}
+
+		if page.Next == "" {
+			return n, nil
+		}
+
+		filter.Cursor = page.Next
+	}
+}
+
+// importDecks saves the decks read from rd which match filter into r. Decks already stored are skipped unless replace
+// is set, expired ones always are. An invalid line stops the import, while a dry run reports every invalid line
+// to errw and saves nothing
+func importDecks(ctx context.Context, r undeck.Repo, filter undeck.ListFilter, rd io.Reader, errw io.Writer, dryRun, replace bool) (importStats, error) {
+	var (
+		stats importStats
+		s     = bufio.NewScanner(rd)
+		line  int
+	)
+
+	s.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	for s.Scan() {
+		line++
+
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var d, err = undeck.UnmarshalDeck(s.Bytes())
+		if err == nil && d.ID == "" {
+			err = errNoID
+		}
+
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", line, err)
+
+			if !dryRun {
+				return stats, err
+			}
+
+			fmt.Fprintln(errw, err)
+			stats.Invalid++
+
+			continue
+		}
+
+		if !filter.Match(d) {
+			continue
+		}
+
+		stored, err := r.Find(ctx, d.ID)
+
+		switch {
+		case err == nil && replace:
+			d.Version = stored.Version
+		case err == nil, errors.Is(err, undeck.ErrDeckExpired):
+			stats.Skipped++
+			continue
+		case errors.Is(err, undeck.ErrDeckNotFound):
+			d.Version = 0
+		default:
+			return stats, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if !dryRun {
+			if _, err = r.Save(ctx, d); err != nil {
+				return stats, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		stats.Imported++
+	}
+
+	if err := s.Err(); err != nil {
+		return stats, err
+	}
+
+	if stats.Invalid > 0 {
+		return stats, fmt.Errorf("%d invalid decks", stats.Invalid)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo/memory"
+	"strings"
+	"testing"
+)
+
+func TestTransfer_RoundTrip(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		src     = memory.New()
+		dst     = memory.New()
+		out     bytes.Buffer
+		full, _ = src.Save(ctx, undeck.Deck{ID: "1", System: french.System}.Add(french.All()...))
+		empty   = 0
+	)
+
+	drawn, _, _ := undeck.Deck{ID: "2", System: french.System, Hidden: true}.Add(french.All()...).Draw(52)
+	drawn, _ = src.Save(ctx, drawn)
+
+	n, err := exportDecks(ctx, src, undeck.ListFilter{}, &out)
+	if err != nil || n != 2 {
+		t.Fatalf("exportDecks() = %d, %v, want 2 decks", n, err)
+	}
+
+	if lines := strings.Count(out.String(), "\n"); lines != 2 {
+		t.Fatalf("exported %d lines, want 2", lines)
+	}
+
+	stats, err := importDecks(ctx, dst, undeck.ListFilter{MaxRemaining: &empty}, strings.NewReader(out.String()), &out, false, false)
+	if err != nil || stats != (importStats{Imported: 1}) {
+		t.Fatalf("importDecks() = %+v, %v, want 1 deck imported", stats, err)
+	}
+
+	got, err := dst.Find(ctx, "2")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	internal.AssertCardSlicesEqual(t, drawn.Drawn(), got.Drawn())
+
+	if !got.Hidden || !got.CreatedAt.Equal(drawn.CreatedAt) {
+		t.Errorf("imported deck = %+v, want hidden and created at %v", got, drawn.CreatedAt)
+	}
+
+	if _, err = dst.Find(ctx, full.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() filtered out deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func TestTransfer_Import(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		deck  = `{"schema":1,"id":"1","system":"french","version":3,"cards":["AH","KS"],"drawn":["2D"]}`
+		other = `{"schema":1,"id":"2","system":"french","version":1,"cards":["QH"],"drawn":[]}`
+	)
+
+	tests := []struct {
+		name    string
+		input   string
+		dryRun  bool
+		replace bool
+		want    importStats
+		wantErr bool
+		stored  int
+	}{
+		{name: "new", input: other, want: importStats{Imported: 1}, stored: 2},
+		{name: "existing skipped", input: deck + "\n" + other, want: importStats{Imported: 1, Skipped: 1}, stored: 2},
+		{name: "existing replaced", input: deck, replace: true, want: importStats{Imported: 1}, stored: 1},
+		{name: "blank lines", input: "\n" + other + "\n\n", want: importStats{Imported: 1}, stored: 2},
+		{name: "dry run", input: other, dryRun: true, want: importStats{Imported: 1}, stored: 1},
+		{name: "invalid", input: `{"id":"3","cards":["XX"]}` + "\n" + other, wantErr: true, stored: 1},
+		{name: "no id", input: `{"schema":1,"cards":[]}`, wantErr: true, stored: 1},
+		{name: "dry run invalid", input: "nope\n" + other + "\n{", dryRun: true, want: importStats{Imported: 1, Invalid: 2}, wantErr: true, stored: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r      = memory.New()
+				errs   bytes.Buffer
+				d, err = undeck.UnmarshalDeck([]byte(deck))
+			)
+
+			if err != nil {
+				t.Fatalf("UnmarshalDeck() error = %v", err)
+			}
+
+			d.Version = 0
+			r.Save(ctx, d)
+
+			got, err := importDecks(ctx, r, undeck.ListFilter{}, strings.NewReader(tt.input), &errs, tt.dryRun, tt.replace)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("importDecks() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("importDecks() = %+v, want %+v", got, tt.want)
+			}
+
+			if tt.dryRun && strings.Count(errs.String(), "line ") != tt.want.Invalid {
+				t.Errorf("reported invalid lines:\n%s\nwant %d", errs.String(), tt.want.Invalid)
+			}
+
+			if page, _ := r.List(ctx, undeck.ListFilter{}); len(page.Decks) != tt.stored {
+				t.Errorf("%d decks stored, want %d", len(page.Decks), tt.stored)
+			}
+		})
+	}
+}
+
+func TestTransfer_Open(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage Storage
+		want    error
+	}{
+		{name: "default repo", storage: Storage{}, want: errMemoryRepo},
+		{name: "memory repo", storage: Storage{Repo: "memory"}, want: errMemoryRepo},
+		{name: "memory repo with its wal", storage: Storage{Repo: "memory", WAL: true, WALSync: "never", DataDir: t.TempDir()}},
+		{name: "sqlite repo", storage: Storage{Repo: "sqlite", DataDir: t.TempDir()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tr = &Transfer{Storage: tt.storage}
+
+			var r, err = tr.open(context.Background())
+			if err != tt.want {
+				t.Fatalf("open() error = %v, want %v", err, tt.want)
+			}
+
+			closeRepo(r)
+		})
+	}
+}
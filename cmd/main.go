@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	_ "go.fluxy.net/undeck/cards/french" // registers the default card system of the decks read from the repos
 	"log"
+	"time"
 )
 
 // Version info
@@ -27,7 +29,7 @@ func main() {
 	}
 	rootCmd.AddCommand(cmdVersion)
 
-	var server = Server{
+	var server = &Server{
 		Port: "1337",
 	}
 
@@ -36,8 +38,70 @@ func main() {
 		Short: "Start the server",
 		Run:   server.serveCmd,
 	}
+	server.flags(cmdServe.Flags())
+	cmdServe.Flags().DurationVar(&server.Timeout, "timeout", 10*time.Second, "give up on requests taking longer than this with 504; 0 waits forever")
+	cmdServe.Flags().DurationVar(&server.TTL, "ttl", 0, "expire decks not used for this long, e.g. 24h, with the memory and redis repos; 0 keeps them forever")
+	cmdServe.Flags().DurationVar(&server.TTLGrace, "ttl-grace", time.Hour, "how long expired decks are reported as gone")
+	cmdServe.Flags().StringVar(&server.IDs, "ids", "uuid", "kind of ids given to new decks: uuid, ulid, short or words; short and words are far easier to guess than uuids")
+	cmdServe.Flags().StringVar(&server.IDPrefix, "id-prefix", "", "prefix of the ids of new decks")
+	cmdServe.Flags().DurationVar(&server.SnapshotInterval, "snapshot-interval", 5*time.Minute, "how often all decks of the memory repo are written to disk with --wal")
+	cmdServe.Flags().BoolVar(&server.History, "history", false, "keep the history of every deck for replay, in memory only: it is lost on restart and not shared between servers")
+	cmdServe.Flags().DurationVar(&server.HistoryRetention, "history-retention", 30*24*time.Hour, "drop the history of decks unchanged for this long; 0 keeps it until the server stops")
+	cmdServe.Flags().IntVar(&server.UndoDepth, "undo-depth", 0, "how many changes to each deck can be undone; 0 disables undo")
+	cmdServe.Flags().IntVar(&server.CacheSize, "cache-size", 0, "how many recently used decks are cached in memory; 0 disables the cache")
+	cmdServe.Flags().DurationVar(&server.CacheMaxAge, "cache-max-age", 0, "how long a deck stays cached before it is reloaded, below --ttl and at most half of it when set; 0 keeps it until evicted, which the file and sqlite repos do not allow")
+	cmdServe.Flags().StringVar(&server.APIKeys, "api-keys", "", "file of api keys and the tenants they belong to, one \"key tenant [quota]\" per line")
+	cmdServe.Flags().BoolVar(&server.TenantHeader, "tenant-header", false, "take the tenant of requests from the X-Tenant header set by a trusted proxy")
+	cmdServe.Flags().IntVar(&server.TenantQuota, "tenant-quota", 0, "how many decks each tenant may keep; 0 for no limit")
+	cmdServe.Flags().StringVar(&server.AdminAddr, "admin-addr", "", "host:port of the listener for operators listing every deck and serving /debug/vars, e.g. 127.0.0.1:1338; empty for none")
 	rootCmd.AddCommand(cmdServe)
 
+	var maintenance = &Maintenance{}
+
+	var cmdRepo = &cobra.Command{
+		Use:   "repo",
+		Short: "Maintain the repo storing decks",
+	}
+	cmdRepo.PersistentFlags().StringVar(&maintenance.DataDir, "data-dir", "data", "directory of the repo")
+
+	var cmdCompact = &cobra.Command{
+		Use:   "compact",
+		Short: "Reclaim the space of deleted decks in the bolt repo, the server must be stopped",
+		Args:  cobra.NoArgs,
+		RunE:  maintenance.compactCmd,
+	}
+	cmdRepo.AddCommand(cmdCompact)
+	rootCmd.AddCommand(cmdRepo)
+
+	var transfer = &Transfer{}
+
+	var cmdExport = &cobra.Command{
+		Use:          "export",
+		Short:        "Write the decks of a repo to stdout as JSON lines",
+		Args:         cobra.NoArgs,
+		RunE:         transfer.exportCmd,
+		SilenceUsage: true,
+	}
+
+	var cmdImport = &cobra.Command{
+		Use:          "import",
+		Short:        "Save the decks read from stdin as JSON lines into a repo",
+		Args:         cobra.NoArgs,
+		RunE:         transfer.importCmd,
+		SilenceUsage: true,
+	}
+	cmdImport.Flags().BoolVar(&transfer.DryRun, "dry-run", false, "validate the decks without saving them")
+	cmdImport.Flags().BoolVar(&transfer.Replace, "replace", false, "replace decks already stored instead of skipping them")
+
+	for _, c := range []*cobra.Command{cmdExport, cmdImport} {
+		transfer.flags(c.Flags())
+		c.Flags().StringVar(&transfer.CreatedBefore, "created-before", "", "only decks created before this RFC 3339 time")
+		c.Flags().IntVar(&transfer.MinRemaining, "min-remaining", 0, "only decks with at least this many cards left")
+		c.Flags().IntVar(&transfer.MaxRemaining, "max-remaining", 0, "only decks with at most this many cards left")
+		c.Flags().StringVar(&transfer.Tenant, "tenant", "", "only decks of this tenant, with their ids as the tenant sees them")
+		rootCmd.AddCommand(c)
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Println("failed to execute command: ", err.Error())
 	}
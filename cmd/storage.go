@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/bolt"
+	"go.fluxy.net/undeck/repo/file"
+	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/redis"
+	"go.fluxy.net/undeck/repo/sqlite"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errNoExpiry means a ttl was set for a repo which does not expire decks, which would silently keep them forever
+var errNoExpiry = errors.New("only the memory and redis repos expire decks, remove --ttl or choose one of them")
+
+// Storage of decks shared by the commands reading or writing them
+type Storage struct {
+	// TTL after which unused decks expire, zero to keep them forever
+	TTL time.Duration
+
+	// TTLGrace is how long expired decks are reported as gone instead of not found
+	TTLGrace time.Duration
+
+	// Repo is the kind of storage for decks: memory, file, sqlite, bolt or redis
+	Repo string
+
+	// DataDir is where decks are stored by the file, sqlite and bolt repos
+	DataDir string
+
+	// RedisAddr is the host:port of the redis server used by the redis repo
+	RedisAddr string
+
+	// IDs is the kind of ids given to new decks: uuid, ulid, short or words
+	IDs string
+
+	// IDPrefix is put in front of the ids of new decks
+	IDPrefix string
+
+	// WAL keeps the decks of the memory repo in DataDir with a write-ahead log so that they survive restarts
+	WAL bool
+
+	// WALSync is how often the write-ahead log is flushed to disk: always, interval or never
+	WALSync string
+
+	// SnapshotInterval is how often all decks of the memory repo are written to disk when WAL is set
+	SnapshotInterval time.Duration
+}
+
+// syncPolicies by name
+var syncPolicies = map[string]memory.SyncPolicy{
+	"always":   memory.SyncAlways,
+	"interval": memory.SyncInterval,
+	"never":    memory.SyncNever,
+}
+
+// flags choosing where decks are stored
+func (s *Storage) flags(f *pflag.FlagSet) {
+	f.StringVar(&s.Repo, "repo", "memory", "where decks are stored: memory, file, sqlite, bolt or redis")
+	f.StringVar(&s.DataDir, "data-dir", "data", "directory of the file, sqlite and bolt repos")
+	f.StringVar(&s.RedisAddr, "redis-addr", "localhost:6379", "address of the redis server of the redis repo")
+	f.BoolVar(&s.WAL, "wal", false, "keep the decks of the memory repo in the data directory with a write-ahead log")
+	f.StringVar(&s.WALSync, "wal-sync", "interval", "how often the write-ahead log is flushed to disk: always, interval (every second) or never")
+}
+
+// shared reports whether other processes may change the decks of the repo while it is open without telling it, e.g.
+// an import into the sqlite database of a server. Memory and bolt repos are held by a single process, redis reports
+// changes
+func (s *Storage) shared() bool {
+	return s.Repo == "file" || s.Repo == "sqlite"
+}
+
+// idGenerator for the kind of ids chosen
+func (s *Storage) idGenerator() (repo.IDGenerator, error) {
+	var gen repo.IDGenerator
+
+	switch s.IDs {
+	case "", "uuid":
+		gen = repo.UUID()
+	case "ulid":
+		gen = repo.ULID()
+	case "short":
+		gen = repo.Short(6)
+	case "words":
+		gen = repo.Words()
+	default:
+		return nil, fmt.Errorf("unknown ids %q", s.IDs)
+	}
+
+	if s.IDPrefix != "" {
+		gen = repo.Prefixed(s.IDPrefix, gen)
+	}
+
+	return gen, nil
+}
+
+// open the repo of the kind of storage chosen
+func (s *Storage) open(ctx context.Context) (undeck.Repo, error) {
+	var ids, err = s.idGenerator()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.TTL > 0 && (s.Repo == "file" || s.Repo == "sqlite" || s.Repo == "bolt") {
+		return nil, errNoExpiry
+	}
+
+	switch s.Repo {
+	case "", "memory":
+		var opts = []memory.Option{memory.WithTTL(s.TTL, s.TTLGrace), memory.WithIDGenerator(ids)}
+
+		if !s.WAL {
+			var r = memory.New(opts...)
+
+			if s.TTL > 0 {
+				go r.Janitor(ctx, s.TTL/10)
+			}
+
+			return r, nil
+		}
+
+		var policy, ok = syncPolicies[s.WALSync]
+		if !ok {
+			return nil, fmt.Errorf("unknown wal sync %q", s.WALSync)
+		}
+
+		r, err := memory.Open(filepath.Join(s.DataDir, "memory"), append(opts, memory.WithSync(policy, memory.DefaultSyncInterval))...)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.SnapshotInterval > 0 {
+			go func() {
+				if err := r.Snapshots(ctx, s.SnapshotInterval); err != nil {
+					log.Println("snapshots stopped: ", err.Error())
+				}
+			}()
+		}
+
+		if s.TTL > 0 {
+			go r.Janitor(ctx, s.TTL/10)
+		}
+
+		return r, nil
+	case "file":
+		return file.New(s.DataDir, file.WithIDGenerator(ids))
+	case "sqlite":
+		if err := os.MkdirAll(s.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+
+		return sqlite.New(filepath.Join(s.DataDir, "undeck.db"), sqlite.WithIDGenerator(ids))
+	case "bolt":
+		if err := os.MkdirAll(s.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+
+		return bolt.New(filepath.Join(s.DataDir, "undeck.bolt"), bolt.WithIDGenerator(ids))
+	case "redis":
+		var client = goredis.NewClient(&goredis.Options{Addr: s.RedisAddr})
+
+		if err := client.Ping(ctx).Err(); err != nil {
+			return nil, err
+		}
+
+		return redis.New(client, redis.WithTTL(s.TTL, s.TTLGrace), redis.WithIDGenerator(ids)), nil
+	}
+
+	return nil, fmt.Errorf("unknown repo %q", s.Repo)
+}
+
+// closeRepo closes repos holding files or a database open
+func closeRepo(r undeck.Repo) {
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStorage_Open(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage Storage
+		want    error
+	}{
+		{name: "memory repo with ttl", storage: Storage{TTL: time.Hour}},
+		{name: "file repo", storage: Storage{Repo: "file", DataDir: t.TempDir()}},
+		{name: "file repo with ttl", storage: Storage{Repo: "file", DataDir: t.TempDir(), TTL: time.Hour}, want: errNoExpiry},
+		{name: "sqlite repo with ttl", storage: Storage{Repo: "sqlite", DataDir: t.TempDir(), TTL: time.Hour}, want: errNoExpiry},
+		{name: "bolt repo with ttl", storage: Storage{Repo: "bolt", DataDir: t.TempDir(), TTL: time.Hour}, want: errNoExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var r, err = tt.storage.open(ctx)
+			if err != tt.want {
+				t.Fatalf("open() error = %v, want %v", err, tt.want)
+			}
+
+			closeRepo(r)
+		})
+	}
+}
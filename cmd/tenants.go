@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tenantKeys are the api keys of the tenants sharing a server and the quotas set for some of them
+type tenantKeys struct {
+	// tenants by api key
+	tenants map[string]string
+
+	// quotas of the tenants which do not get the default one
+	quotas map[string]int
+}
+
+// loadTenantKeys reads the api keys file at path, see readTenantKeys
+func loadTenantKeys(path string) (tenantKeys, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		return tenantKeys{}, err
+	}
+
+	defer internal.Closed(f)
+
+	return readTenantKeys(f)
+}
+
+// readTenantKeys reads one api key per line followed by its tenant and optionally the deck quota of the tenant,
+// separated by spaces. Blank lines and lines starting with # are skipped
+func readTenantKeys(rd io.Reader) (tenantKeys, error) {
+	var (
+		keys = tenantKeys{tenants: make(map[string]string), quotas: make(map[string]int)}
+		s    = bufio.NewScanner(rd)
+		line int
+	)
+
+	for s.Scan() {
+		line++
+
+		var fields = strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) < 2 || len(fields) > 3 {
+			return keys, fmt.Errorf("line %d: want an api key, a tenant and optionally a quota", line)
+		}
+
+		if err := repo.ValidTenant(fields[1]); err != nil {
+			return keys, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if _, ok := keys.tenants[fields[0]]; ok {
+			return keys, fmt.Errorf("line %d: duplicate api key", line)
+		}
+
+		keys.tenants[fields[0]] = fields[1]
+
+		if len(fields) == 3 {
+			var q, err = strconv.Atoi(fields[2])
+			if err != nil || q < 0 {
+				return keys, fmt.Errorf("line %d: invalid quota %q", line, fields[2])
+			}
+
+			keys.quotas[fields[1]] = q
+		}
+	}
+
+	return keys, s.Err()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadTenantKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantTenants map[string]string
+		wantQuotas  map[string]int
+		wantErr     bool
+	}{
+		{
+			name:        "keys",
+			input:       "# key tenant quota\nk1 acme\n\nk2 umbra 50\nk3 acme\n",
+			wantTenants: map[string]string{"k1": "acme", "k2": "umbra", "k3": "acme"},
+			wantQuotas:  map[string]int{"umbra": 50},
+		},
+		{name: "no tenant", input: "k1\n", wantErr: true},
+		{name: "too many fields", input: "k1 acme 5 6\n", wantErr: true},
+		{name: "invalid tenant", input: "k1 acme:blue\n", wantErr: true},
+		{name: "invalid quota", input: "k1 acme -1\n", wantErr: true},
+		{name: "duplicate key", input: "k1 acme\nk1 umbra\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = readTenantKeys(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readTenantKeys() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(got.tenants) != len(tt.wantTenants) || len(got.quotas) != len(tt.wantQuotas) {
+				t.Fatalf("readTenantKeys() = %v, %v, want %v, %v", got.tenants, got.quotas, tt.wantTenants, tt.wantQuotas)
+			}
+
+			for k, v := range tt.wantTenants {
+				if got.tenants[k] != v {
+					t.Errorf("readTenantKeys() tenant of %s = %q, want %q", k, got.tenants[k], v)
+				}
+			}
+
+			for k, v := range tt.wantQuotas {
+				if got.quotas[k] != v {
+					t.Errorf("readTenantKeys() quota of %s = %d, want %d", k, got.quotas[k], v)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package undeck
 
 import (
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -28,8 +29,15 @@ func RandomShuffler(d Deck) Deck {
 func OneTwoSwapShuffler(d Deck) Deck {
 	var dup = Deck{
 		ID:         d.ID,
+		ParentID:   d.ParentID,
+		System:     d.System,
 		IsShuffled: true,
+		Hidden:     d.Hidden,
+		Version:    d.Version,
+		CreatedAt:  d.CreatedAt,
+		AccessedAt: d.AccessedAt,
 		cards:      d.Cards(),
+		drawn:      d.Drawn(),
 	}
 
 	if len(dup.cards) > 2 {
@@ -39,12 +47,33 @@ func OneTwoSwapShuffler(d Deck) Deck {
 	return dup
 }
 
+// Pile of cards of a deck
+type Pile string
+
+const (
+	// PileDeck is the remaining cards, still to be drawn
+	PileDeck Pile = "deck"
+
+	// PileDrawn is the cards drawn so far, e.g. the hand of a player
+	PileDrawn Pile = "drawn"
+)
+
+// Order reports whether card a should be placed before card b when sorting
+type Order func(a, b Card) bool
+
 // Deck is an implementation of a deck suitable for most cases
 type Deck struct {
 	ID         string
+	ParentID   string // id of the deck this deck was forked from, if any
+	System     string // name of the registered card system of the cards, DefaultSystem if empty
 	IsShuffled bool
+	Hidden     bool // the order of the remaining cards must not be revealed
 	Shuffler   ShufflerFunc
+	Version    int // incremented by the repo each time the deck is saved
+	CreatedAt  time.Time
+	AccessedAt time.Time
 	cards      []Card
+	drawn      []Card
 }
 
 func (d Deck) Remaining() int {
@@ -80,6 +109,7 @@ func (d Deck) Draw(count int) (Deck, []Card, error) {
 	}
 
 	d.cards = d.cards[count:]
+	d.drawn = append(d.Drawn(), c...)
 
 	return d, c, nil
 }
@@ -92,12 +122,68 @@ func (d Deck) Shuffle() Deck {
 	return d.Shuffler(d)
 }
 
+// Sort the remaining cards by order, cards which compare equal keep their relative positions
+func (d Deck) Sort(order Order) Deck {
+	d.cards = d.Cards()
+	d.IsShuffled = false
+
+	sort.SliceStable(d.cards, func(i, j int) bool {
+		return order(d.cards[i], d.cards[j])
+	})
+
+	return d
+}
+
+// SortPile sorts a pile of the deck by order, sorting the drawn cards leaves the remaining ones as they are
+func (d Deck) SortPile(pile Pile, order Order) (Deck, error) {
+	switch pile {
+	case PileDeck:
+		return d.Sort(order), nil
+	case PileDrawn:
+		d.drawn = d.Drawn()
+
+		sort.SliceStable(d.drawn, func(i, j int) bool {
+			return order(d.drawn[i], d.drawn[j])
+		})
+
+		return d, nil
+	}
+
+	return d, ErrInvalidPile
+}
+
 func (d Deck) Duplicate() Deck {
 	return Deck{
 		ID:         d.ID,
+		ParentID:   d.ParentID,
+		System:     d.System,
 		IsShuffled: d.IsShuffled,
+		Hidden:     d.Hidden,
+		Shuffler:   d.Shuffler,
+		Version:    d.Version,
+		CreatedAt:  d.CreatedAt,
+		AccessedAt: d.AccessedAt,
 		cards:      d.Cards(),
+		drawn:      d.Drawn(),
+	}
+}
+
+// Fork copies the remaining cards of the deck into child, a new deck made by a repo, along with the drawn cards if
+// piles is set. The fork keeps the id and timestamps of child and links back to the deck through its ParentID
+func (d Deck) Fork(child Deck, piles bool) Deck {
+	var f = d.Duplicate()
+
+	if !piles {
+		f.drawn = nil
 	}
+
+	f.ID = child.ID
+	f.ParentID = d.ID
+	f.Version = child.Version
+	f.CreatedAt = child.CreatedAt
+	f.AccessedAt = child.AccessedAt
+
+	return f
 }
 
 func (d Deck) Cards() []Card {
@@ -109,3 +195,35 @@ func (d Deck) Cards() []Card {
 
 	return cards
 }
+
+// Drawn returns the cards drawn from the deck so far, in the order they were drawn
+func (d Deck) Drawn() []Card {
+	var cards []Card
+
+	for i := range d.drawn {
+		cards = append(cards, d.drawn[i].Duplicate())
+	}
+
+	return cards
+}
+
+// LastAccess is when the deck was last used, its creation time if it was never accessed
+func (d Deck) LastAccess() time.Time {
+	if d.AccessedAt.IsZero() {
+		return d.CreatedAt
+	}
+
+	return d.AccessedAt
+}
+
+// WithDrawn returns the deck with cards recorded as already drawn, e.g. when restoring a stored deck
+func (d Deck) WithDrawn(cards ...Card) Deck {
+	var c []Card
+
+	for i := range cards {
+		c = append(c, cards[i].Duplicate())
+	}
+
+	d.drawn = c
+	return d
+}
@@ -2,6 +2,7 @@ package undeck
 
 import (
 	"testing"
+	"time"
 )
 
 type testranksuit struct {
@@ -180,3 +181,185 @@ func TestDeck_Add(t *testing.T) {
 		})
 	}
 }
+
+func TestDeck_Sort(t *testing.T) {
+	var byShort Order = func(a, b Card) bool {
+		return a.String() < b.String()
+	}
+
+	tests := []struct {
+		name  string
+		cards []Card
+		want  []Card
+	}{
+		{
+			name:  "empty",
+			cards: nil,
+			want:  nil,
+		},
+		{
+			name: "unsorted",
+			cards: []Card{
+				testcard("King", "K", "Hearts", "H"),
+				testcard("Ace", "A", "Hearts", "H"),
+				testcard("Queen", "Q", "Hearts", "H"),
+			},
+			want: []Card{
+				testcard("Ace", "A", "Hearts", "H"),
+				testcard("King", "K", "Hearts", "H"),
+				testcard("Queen", "Q", "Hearts", "H"),
+			},
+		},
+		{
+			name: "equal cards keep their positions",
+			cards: []Card{
+				testcard("King", "K", "Hearts", "H"),
+				testcard("Ace", "A", "Spades", "H"),
+				testcard("Ace", "A", "Hearts", "H"),
+			},
+			want: []Card{
+				testcard("Ace", "A", "Spades", "H"),
+				testcard("Ace", "A", "Hearts", "H"),
+				testcard("King", "K", "Hearts", "H"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Deck{ID: "1", IsShuffled: true, cards: tt.cards}
+
+			got := d.Sort(byShort)
+
+			if !assertCardSlicesEqual(t, tt.want, got.cards) {
+				t.Errorf("tt.want != got")
+			}
+
+			if got.IsShuffled {
+				t.Errorf("sorted deck should not be shuffled")
+			}
+
+			if !assertCardSlicesEqual(t, tt.cards, d.cards) {
+				t.Errorf("original deck changed: tt.cards != d.cards")
+			}
+		})
+	}
+}
+
+func TestDeck_Draw(t *testing.T) {
+	var d = Deck{ID: "1"}.Add(
+		testcard("Ace", "A", "Hearts", "H"),
+		testcard("King", "K", "Hearts", "H"),
+		testcard("Queen", "Q", "Hearts", "H"),
+	)
+
+	d1, c1, err := d.Draw(2)
+	if err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	d2, c2, err := d1.Draw(1)
+	if err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	if _, _, err = d2.Draw(1); err != ErrNotEnoughCards {
+		t.Errorf("Draw() error = %v, want %v", err, ErrNotEnoughCards)
+	}
+
+	assertCardSlicesEqual(t, []Card{testcard("Ace", "A", "Hearts", "H"), testcard("King", "K", "Hearts", "H")}, c1)
+	assertCardSlicesEqual(t, []Card{testcard("Queen", "Q", "Hearts", "H")}, c2)
+	assertCardSlicesEqual(t, c1, d1.Drawn())
+	assertCardSlicesEqual(t, append(c1, c2...), d2.Drawn())
+
+	if len(d.Drawn()) != 0 {
+		t.Errorf("original deck changed: %d cards drawn", len(d.Drawn()))
+	}
+
+	if d2.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want 0", d2.Remaining())
+	}
+}
+
+func TestDeck_Fork(t *testing.T) {
+	var (
+		created = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		d = Deck{ID: "1", IsShuffled: true, Version: 4, Shuffler: OneTwoSwapShuffler}.Add(
+			testcard("Ace", "A", "Hearts", "H"),
+			testcard("King", "K", "Hearts", "H"),
+			testcard("Queen", "Q", "Hearts", "H"),
+		)
+	)
+
+	d, _, _ = d.Draw(1)
+
+	if f := d.Fork(Deck{ID: "2"}, false); len(f.Drawn()) != 0 || f.Remaining() != 2 {
+		t.Errorf("Fork() without piles = %d remaining and %d drawn cards, want 2 and none", f.Remaining(), len(f.Drawn()))
+	}
+
+	var f = d.Fork(Deck{ID: "2", CreatedAt: created}, true)
+
+	if f.ID != "2" || f.ParentID != "1" || f.Version != 0 || !f.CreatedAt.Equal(created) || !f.IsShuffled {
+		t.Errorf("Fork() = %+v, want deck 2 forked from 1 at version 0", f)
+	}
+
+	if f.Shuffler == nil {
+		t.Errorf("Fork() dropped the shuffler")
+	}
+
+	assertCardSlicesEqual(t, d.Cards(), f.Cards())
+	assertCardSlicesEqual(t, d.Drawn(), f.Drawn())
+
+	f, _, _ = f.Draw(1)
+
+	if d.Remaining() != 2 {
+		t.Errorf("original deck changed: Remaining() = %d, want 2", d.Remaining())
+	}
+}
+
+func TestDeck_SortPile(t *testing.T) {
+	var byShort Order = func(a, b Card) bool {
+		return a.String() < b.String()
+	}
+
+	var (
+		ace   = testcard("Ace", "A", "Hearts", "H")
+		king  = testcard("King", "K", "Hearts", "H")
+		queen = testcard("Queen", "Q", "Hearts", "H")
+		d     = Deck{ID: "1", IsShuffled: true}.Add(queen, ace).WithDrawn(king, queen, ace)
+	)
+
+	tests := []struct {
+		name      string
+		pile      Pile
+		wantCards []Card
+		wantDrawn []Card
+		wantErr   error
+	}{
+		{name: "deck", pile: PileDeck, wantCards: []Card{ace, queen}, wantDrawn: []Card{king, queen, ace}},
+		{name: "drawn", pile: PileDrawn, wantCards: []Card{queen, ace}, wantDrawn: []Card{ace, king, queen}},
+		{name: "unknown", pile: "discard", wantCards: []Card{queen, ace}, wantDrawn: []Card{king, queen, ace}, wantErr: ErrInvalidPile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.SortPile(tt.pile, byShort)
+			if err != tt.wantErr {
+				t.Fatalf("SortPile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !assertCardSlicesEqual(t, tt.wantCards, got.cards) || !assertCardSlicesEqual(t, tt.wantDrawn, got.drawn) {
+				t.Errorf("SortPile() = %v drawn %v, want %v drawn %v", got.cards, got.drawn, tt.wantCards, tt.wantDrawn)
+			}
+
+			if got.IsShuffled != (tt.pile != PileDeck) {
+				t.Errorf("SortPile() shuffled = %t", got.IsShuffled)
+			}
+		})
+	}
+
+	if !assertCardSlicesEqual(t, []Card{king, queen, ace}, d.drawn) {
+		t.Errorf("original deck changed: drawn = %v", d.drawn)
+	}
+}
@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrFileLocked indicates that another process holds the lock of a file
+var ErrFileLocked = errors.New("file is locked by another process")
+
+// maxLockWait between two attempts at taking the lock of a file held by another process
+const maxLockWait = 50 * time.Millisecond
+
+// LockFile takes the lock of f, shared or exclusive, retrying while another process holds it until ctx is done
+func LockFile(ctx context.Context, f *os.File, exclusive bool) error {
+	for wait := time.Millisecond; ; wait = min(2*wait, maxLockWait) {
+		if err := TryLockFile(f, exclusive); err != ErrFileLocked {
+			return err
+		}
+
+		var t = time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
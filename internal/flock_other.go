@@ -0,0 +1,16 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package internal
+
+import "os"
+
+// TryLockFile is not supported on this platform, files are then only safe within a single process
+func TryLockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// UnlockFile is not supported on this platform
+func UnlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// TryLockFile takes the lock of f, shared or exclusive, failing with ErrFileLocked if another process holds it.
+// Locks are held by open files, opening the same file twice within a process gives two locks
+func TryLockFile(f *os.File, exclusive bool) error {
+	var how = syscall.LOCK_SH
+
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err == syscall.EWOULDBLOCK {
+		return ErrFileLocked
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnlockFile releases the lock of f, closing f releases it too
+func UnlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
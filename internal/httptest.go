@@ -90,6 +90,10 @@ func (h HttpTest) Assert(t *testing.T) {
 		return
 	}
 
+	for k, v := range h.Request.Header {
+		r.Header[k] = v
+	}
+
 	w := httptest.NewRecorder()
 
 	h.Handler.ServeHTTP(w, r)
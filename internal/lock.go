@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"context"
+	"golang.org/x/sync/semaphore"
+)
+
+// maxReaders holding a RWLock at once, a writer takes all of them
+const maxReaders = 1 << 30
+
+// NewRWLock that is not held
+func NewRWLock() *RWLock {
+	return &RWLock{sem: semaphore.NewWeighted(maxReaders)}
+}
+
+// RWLock is a readers-writer lock whose callers stop waiting for it once their context is done, unlike a
+// sync.RWMutex. Callers get the lock in the order they asked for it, so that a waiting writer keeps new readers out
+type RWLock struct {
+	sem *semaphore.Weighted
+}
+
+// Lock for writing, failing with the error of ctx if it is done first
+func (l *RWLock) Lock(ctx context.Context) error {
+	return l.sem.Acquire(ctx, maxReaders)
+}
+
+// Unlock for writing
+func (l *RWLock) Unlock() {
+	l.sem.Release(maxReaders)
+}
+
+// RLock for reading, failing with the error of ctx if it is done first
+func (l *RWLock) RLock(ctx context.Context) error {
+	return l.sem.Acquire(ctx, 1)
+}
+
+// RUnlock for reading
+func (l *RWLock) RUnlock() {
+	l.sem.Release(1)
+}
@@ -78,11 +78,26 @@ func AssertDecksEqual(t *testing.T, a, b undeck.Deck) bool {
 		return false
 	}
 
+	if a.ParentID != b.ParentID {
+		t.Errorf("parent ids not same\nwant = %s\ngot  = %s", a.ParentID, b.ParentID)
+		return false
+	}
+
 	if a.IsShuffled != b.IsShuffled {
 		t.Errorf("shuffled not same\nwant = %t\ngot  = %t", a.IsShuffled, b.IsShuffled)
 		return false
 	}
 
+	if a.Hidden != b.Hidden {
+		t.Errorf("hidden not same\nwant = %t\ngot  = %t", a.Hidden, b.Hidden)
+		return false
+	}
+
+	if a.Version != b.Version {
+		t.Errorf("version not same\nwant = %d\ngot  = %d", a.Version, b.Version)
+		return false
+	}
+
 	if a.Remaining() != b.Remaining() {
 		t.Errorf("remaining not same\nwant = %d\ngot  = %d", a.Remaining(), b.Remaining())
 		return false
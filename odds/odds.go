@@ -0,0 +1,116 @@
+// Package odds computes exact probabilities of drawing cards from the remaining composition of a deck
+package odds
+
+import (
+	"errors"
+	"go.fluxy.net/undeck"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrInvalidQuery indicates that the number of draws or successes asked for is not possible
+	ErrInvalidQuery = errors.New("odds query is not valid")
+)
+
+// Matcher reports whether a card counts as a success
+type Matcher func(c undeck.Card) bool
+
+// Suit matches cards by the short or long name of their suit, e.g. H or HEARTS
+func Suit(s string) Matcher {
+	return func(c undeck.Card) bool {
+		return strings.EqualFold(c.Suit.Short(), s) || strings.EqualFold(c.Suit.String(), s)
+	}
+}
+
+// Rank matches cards by the short or long name of their rank, e.g. A or ACE
+func Rank(r string) Matcher {
+	return func(c undeck.Card) bool {
+		return strings.EqualFold(c.Rank.Short(), r) || strings.EqualFold(c.Rank.String(), r)
+	}
+}
+
+// Codes matches any of the cards given by code, e.g. AS or KH
+func Codes(codes ...string) Matcher {
+	return func(c undeck.Card) bool {
+		for i := range codes {
+			if strings.EqualFold(c.String(), codes[i]) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// All matches cards satisfying every matcher
+func All(m ...Matcher) Matcher {
+	return func(c undeck.Card) bool {
+		for i := range m {
+			if !m[i](c) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Odds of drawing at least a number of matching cards, only counts are kept so the order of the deck is not revealed
+type Odds struct {
+	Remaining   int
+	Matching    int
+	Draws       int
+	AtLeast     int
+	Probability *big.Rat
+}
+
+// Float approximation of the probability
+func (o Odds) Float() float64 {
+	var f, _ = o.Probability.Float64()
+	return f
+}
+
+// AtLeast computes the hypergeometric probability of getting at least atLeast matching cards in the next draws cards
+func AtLeast(d undeck.Deck, m Matcher, draws, atLeast int) (Odds, error) {
+	var o = Odds{
+		Remaining:   d.Remaining(),
+		Draws:       draws,
+		AtLeast:     atLeast,
+		Probability: new(big.Rat),
+	}
+
+	if draws < 0 || atLeast < 0 {
+		return o, ErrInvalidQuery
+	}
+
+	if draws > o.Remaining {
+		return o, undeck.ErrNotEnoughCards
+	}
+
+	for _, c := range d.Cards() {
+		if m(c) {
+			o.Matching++
+		}
+	}
+
+	var (
+		total = new(big.Int).Binomial(int64(o.Remaining), int64(draws))
+		ways  = new(big.Int)
+		term  = new(big.Int)
+	)
+
+	for i := atLeast; i <= draws && i <= o.Matching; i++ {
+		if draws-i > o.Remaining-o.Matching {
+			continue
+		}
+
+		term.Binomial(int64(o.Matching), int64(i))
+		term.Mul(term, new(big.Int).Binomial(int64(o.Remaining-o.Matching), int64(draws-i)))
+		ways.Add(ways, term)
+	}
+
+	o.Probability.SetFrac(ways, total)
+
+	return o, nil
+}
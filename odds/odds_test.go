@@ -0,0 +1,93 @@
+package odds
+
+import (
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards"
+	"go.fluxy.net/undeck/cards/french"
+	"testing"
+)
+
+func TestAtLeast(t *testing.T) {
+	type args struct {
+		deck    undeck.Deck
+		matcher Matcher
+		draws   int
+		atLeast int
+	}
+
+	type want struct {
+		matching    int
+		probability string
+		err         error
+	}
+
+	var full = undeck.Deck{ID: "1"}.Add(french.All()...)
+
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{
+			name: "next card is a heart",
+			args: args{deck: full, matcher: Suit("H"), draws: 1, atLeast: 1},
+			want: want{matching: 13, probability: "1/4"},
+		},
+		{
+			name: "at least one ace in the next 5",
+			args: args{deck: full, matcher: Rank("ACE"), draws: 5, atLeast: 1},
+			want: want{matching: 4, probability: "18472/54145"},
+		},
+		{
+			name: "more matches than matching cards",
+			args: args{deck: full, matcher: Codes("AS", "AH", "AD", "AC"), draws: 5, atLeast: 5},
+			want: want{matching: 4, probability: "0/1"},
+		},
+		{
+			name: "at least nothing",
+			args: args{deck: full, matcher: Suit("H"), draws: 3, atLeast: 0},
+			want: want{matching: 13, probability: "1/1"},
+		},
+		{
+			name: "every remaining card matches",
+			args: args{
+				deck:    undeck.Deck{ID: "1"}.Add(cards.MustString(french.FromString, "AH,KH")...),
+				matcher: All(Suit("hearts"), Rank("K")),
+				draws:   2,
+				atLeast: 1,
+			},
+			want: want{matching: 1, probability: "1/1"},
+		},
+		{
+			name: "more draws than remaining",
+			args: args{deck: full, matcher: Suit("H"), draws: 53, atLeast: 1},
+			want: want{err: undeck.ErrNotEnoughCards},
+		},
+		{
+			name: "negative draws",
+			args: args{deck: full, matcher: Suit("H"), draws: -1, atLeast: 1},
+			want: want{err: ErrInvalidQuery},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AtLeast(tt.args.deck, tt.args.matcher, tt.args.draws, tt.args.atLeast)
+			if err != tt.want.err {
+				t.Fatalf("AtLeast() error = %v, want %v", err, tt.want.err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got.Matching != tt.want.matching {
+				t.Errorf("AtLeast() matching = %d, want %d", got.Matching, tt.want.matching)
+			}
+
+			if got.Probability.String() != tt.want.probability {
+				t.Errorf("AtLeast() probability = %s, want %s", got.Probability.String(), tt.want.probability)
+			}
+		})
+	}
+}
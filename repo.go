@@ -3,20 +3,123 @@ package undeck
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
 	// ErrDeckNotFound in case a deck does not exist
 	ErrDeckNotFound = errors.New("deck not found")
+
+	// ErrDeckExpired in case a deck was evicted after not being used for too long
+	ErrDeckExpired = errors.New("deck expired")
+
+	// ErrVersionConflict in case a deck was changed since it was read
+	ErrVersionConflict = errors.New("deck version conflict")
+
+	// ErrIDCollision in case no free id could be generated for a new deck
+	ErrIDCollision = errors.New("deck id collision")
 )
 
 // Repo is for deck persistence
 type Repo interface {
+	// Create a deck with a new id not used by any stored deck, the deck is only stored once saved
 	Create(ctx context.Context) (Deck, error)
 
-	// Save a deck
+	// Save a deck, failing with ErrVersionConflict if its version is not the one stored
 	Save(ctx context.Context, deck Deck) (Deck, error)
 
 	// Find a deck by id
 	Find(ctx context.Context, id string) (Deck, error)
+
+	// Update a deck atomically, the deck returned by fn is saved unless fn returns an error
+	Update(ctx context.Context, id string, fn UpdateFunc) (Deck, error)
+
+	// Delete a deck by id, unless the check set with WithDeleteCheck rejects it
+	Delete(ctx context.Context, id string) error
+
+	// List decks ordered by id a page at a time
+	List(ctx context.Context, filter ListFilter) (ListPage, error)
+}
+
+// UpdateFunc modifies a deck during Repo.Update
+type UpdateFunc func(Deck) (Deck, error)
+
+// DeleteFunc checks a deck about to be deleted by Repo.Delete, the deck is kept if it returns an error
+type DeleteFunc func(Deck) error
+
+type deleteCheckKey struct{}
+
+// WithDeleteCheck returns a context whose deletes only go through if fn accepts the stored deck, e.g. to check its
+// version. Repos run fn within the same lock or transaction as the delete
+func WithDeleteCheck(ctx context.Context, fn DeleteFunc) context.Context {
+	return context.WithValue(ctx, deleteCheckKey{}, fn)
+}
+
+// DeleteCheck set on ctx, nil if deletes are not checked
+func DeleteCheck(ctx context.Context) DeleteFunc {
+	var fn, _ = ctx.Value(deleteCheckKey{}).(DeleteFunc)
+	return fn
+}
+
+const (
+	// DefaultListLimit is the number of decks per page when ListFilter.Limit is not set
+	DefaultListLimit = 20
+
+	// MaxListLimit is the maximum number of decks per page
+	MaxListLimit = 100
+)
+
+// ListFilter narrows down the decks returned by Repo.List, zero values do not filter
+type ListFilter struct {
+	// Cursor is ListPage.Next of the previous page, empty for the first page
+	Cursor string
+
+	// Limit the number of decks in a page, defaults to DefaultListLimit and is capped to MaxListLimit
+	Limit int
+
+	// CreatedBefore only lists decks created before this time
+	CreatedBefore time.Time
+
+	// MinRemaining only lists decks with at least this many cards left
+	MinRemaining *int
+
+	// MaxRemaining only lists decks with at most this many cards left
+	MaxRemaining *int
+}
+
+// PageSize is the effective Limit of the filter
+func (f ListFilter) PageSize() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultListLimit
+	case f.Limit > MaxListLimit:
+		return MaxListLimit
+	}
+
+	return f.Limit
+}
+
+// Match reports whether a deck satisfies the filter, the cursor and limit are not considered
+func (f ListFilter) Match(d Deck) bool {
+	if !f.CreatedBefore.IsZero() && !d.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	if f.MinRemaining != nil && d.Remaining() < *f.MinRemaining {
+		return false
+	}
+
+	if f.MaxRemaining != nil && d.Remaining() > *f.MaxRemaining {
+		return false
+	}
+
+	return true
+}
+
+// ListPage is a page of decks returned by Repo.List
+type ListPage struct {
+	Decks []Deck
+
+	// Next is the cursor of the following page, empty if this is the last one
+	Next string
 }
@@ -0,0 +1,310 @@
+// Package bolt stores decks in an embedded bbolt key value database, one key per deck
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"go.etcd.io/bbolt"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"os"
+	"sync"
+	"time"
+)
+
+var bucket = []byte("decks")
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithIDGenerator replaces the uuid generator used for new decks
+func WithIDGenerator(fi repo.IDGenerator) Option {
+	return func(r *Repo) {
+		r.idGenerator = fi
+	}
+}
+
+// WithClock replaces the clock used for timestamps
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// New repo using the database file at path, which is created if needed.
+// The file is locked while open so it fails after a second if another process is using it
+func New(path string, opts ...Option) (*Repo, error) {
+	var db, err = bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		var _, err = tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var r = &Repo{
+		db:  db,
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Repo stores each deck binary encoded under its id, every write is a transaction synced to disk
+type Repo struct {
+	mu          sync.Mutex
+	db          *bbolt.DB
+	idGenerator repo.IDGenerator
+	now         repo.Clock
+}
+
+// Close the database
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
+
+func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
+	if err := ctx.Err(); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		d       undeck.Deck
+		id, err = repo.NewID(r.idGenerator, func(id string) (bool, error) {
+			var taken bool
+
+			var err = r.db.View(func(tx *bbolt.Tx) error {
+				taken = tx.Bucket(bucket).Get([]byte(id)) != nil
+				return nil
+			})
+
+			return taken, err
+		})
+	)
+
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = id
+	d.CreatedAt = r.now()
+
+	return d, nil
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var err = r.db.Update(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var b = tx.Bucket(bucket)
+
+		if d, err := r.get(b, deck.ID); err == nil && d.Version != deck.Version {
+			return undeck.ErrVersionConflict
+		} else if err != nil && err != undeck.ErrDeckNotFound {
+			return err
+		}
+
+		deck.Version++
+		deck.AccessedAt = r.now()
+
+		return r.put(b, deck)
+	})
+
+	return deck, err
+}
+
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	var d undeck.Deck
+
+	var err = r.db.View(func(tx *bbolt.Tx) (err error) {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		d, err = r.get(tx.Bucket(bucket), id)
+		return err
+	})
+
+	return d, err
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var d undeck.Deck
+
+	var err = r.db.Update(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var (
+			b            = tx.Bucket(bucket)
+			current, err = r.get(b, id)
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if d, err = fn(current); err != nil {
+			return err
+		}
+
+		d.Version = current.Version + 1
+		d.AccessedAt = r.now()
+
+		return r.put(b, d)
+	})
+
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var b = tx.Bucket(bucket)
+
+		if b.Get([]byte(id)) == nil {
+			return undeck.ErrDeckNotFound
+		}
+
+		if check := undeck.DeleteCheck(ctx); check != nil {
+			var d, err = r.get(b, id)
+			if err != nil {
+				return err
+			}
+
+			if err = check(d); err != nil {
+				return err
+			}
+		}
+
+		return b.Delete([]byte(id))
+	})
+}
+
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	var (
+		page undeck.ListPage
+		size = filter.PageSize()
+	)
+
+	var err = r.db.View(func(tx *bbolt.Tx) error {
+		var (
+			c    = tx.Bucket(bucket).Cursor()
+			k, v = c.Seek([]byte(filter.Cursor))
+		)
+
+		if k != nil && bytes.Equal(k, []byte(filter.Cursor)) {
+			k, v = c.Next()
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var d, err = undeck.UnmarshalDeck(v)
+			if err != nil {
+				return err
+			}
+
+			if !filter.Match(d) {
+				continue
+			}
+
+			if len(page.Decks) == size {
+				page.Next = page.Decks[size-1].ID
+				break
+			}
+
+			page.Decks = append(page.Decks, d)
+		}
+
+		return nil
+	})
+
+	return page, err
+}
+
+func (r *Repo) get(b *bbolt.Bucket, id string) (undeck.Deck, error) {
+	var v = b.Get([]byte(id))
+
+	if v == nil {
+		return undeck.Deck{}, undeck.ErrDeckNotFound
+	}
+
+	return undeck.UnmarshalDeck(v)
+}
+
+func (r *Repo) put(b *bbolt.Bucket, d undeck.Deck) error {
+	var v, err = d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(d.ID), v)
+}
+
+// Compact rewrites the database at path without the free pages left by deleted and updated decks.
+// The database must not be in use, it stays locked until the compacted copy has replaced it
+func Compact(path string) error {
+	var src, err = bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	// a copy left behind by a compaction which was interrupted is started over
+	var tmp = path + ".compact"
+
+	if err = os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	dst, err := bbolt.Open(tmp, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	if err = bbolt.Compact(dst, src, 64<<20); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return src.Close()
+}
@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/repotest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newRepo(t *testing.T, opts ...Option) *Repo {
+	var r, err = New(filepath.Join(t.TempDir(), "undeck.bolt"), opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	t.Cleanup(func() {
+		r.Close()
+	})
+
+	return r
+}
+
+func TestCompact(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		path   = filepath.Join(t.TempDir(), "undeck.bolt")
+		r, err = New(path, WithIDGenerator(repo.Sequential()))
+	)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		var d, _ = r.Create(ctx)
+
+		if _, err = r.Save(ctx, d.Add(french.All()...)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		if i%2 == 0 {
+			r.Delete(ctx, d.ID)
+		}
+	}
+
+	r.Close()
+
+	// left behind by an interrupted compaction
+	if err = os.WriteFile(path+".compact", []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Compact(path); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	if _, err = os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("Compact() left its copy behind, stat error = %v", err)
+	}
+
+	if r, err = New(path); err != nil {
+		t.Fatalf("New() on compacted database error = %v", err)
+	}
+
+	defer r.Close()
+
+	page, err := r.List(ctx, undeck.ListFilter{Limit: undeck.MaxListLimit})
+	if err != nil || len(page.Decks) != 25 {
+		t.Errorf("List() after Compact() = %d decks, want 25 (%v)", len(page.Decks), err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return newRepo(t)
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		return newRepo(t, WithIDGenerator(gen))
+	})
+}
@@ -0,0 +1,235 @@
+// Package cache keeps recently used decks in memory in front of a slower repo
+package cache
+
+import (
+	"container/list"
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithMaxAge reloads decks cached for longer than age, bounding how stale a deck changed by another instance can be
+func WithMaxAge(age time.Duration) Option {
+	return func(r *Repo) {
+		r.maxAge = age
+	}
+}
+
+// WithTTL of the decorated repo, which expires decks not found nor changed for ttl. Decks are reloaded once cached for
+// half of it, so that no deck is served after the decorated repo expired it and decks in use have their expiry
+// refreshed. Decks used only from the cache may thus expire up to ttl/2 early
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Repo) {
+		r.ttl = ttl
+	}
+}
+
+// WithClock replaces the clock used for the age of cached decks
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// Stats of the cache since it was created
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// New decorates repo with a cache of the size most recently used decks
+func New(repo undeck.Repo, size int, opts ...Option) *Repo {
+	var r = &Repo{
+		Repo:    repo,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if half := r.ttl / 2; half > 0 && (r.maxAge <= 0 || r.maxAge > half) {
+		r.maxAge = half
+	}
+
+	return r
+}
+
+// Repo caches the decks found and saved through it. Writes go to the decorated repo first and then to the cache.
+// Finding a cached deck does not reach the decorated repo, so it does not refresh its expiry either, see WithTTL
+type Repo struct {
+	undeck.Repo
+
+	mu      sync.Mutex
+	size    int
+	maxAge  time.Duration
+	ttl     time.Duration
+	now     repo.Clock
+	entries map[string]*list.Element
+	lru     *list.List
+
+	hits, misses, evictions uint64
+}
+
+type entry struct {
+	deck undeck.Deck
+	at   time.Time
+}
+
+// Unwrap returns the decorated repo
+func (r *Repo) Unwrap() undeck.Repo {
+	return r.Repo
+}
+
+// Find a deck by id, in the cache first. Finds with a ctx from repo.WithPeek go to the decorated repo, which knows
+// whether the deck is still there, and leave the cache as it is
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	if err := ctx.Err(); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	if repo.Peek(ctx) {
+		return r.Repo.Find(ctx, id)
+	}
+
+	if d, ok := r.get(id); ok {
+		atomic.AddUint64(&r.hits, 1)
+		return d, nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+
+	var d, err = r.Repo.Find(ctx, id)
+	if err != nil {
+		r.Invalidate(id)
+		return d, err
+	}
+
+	r.put(d)
+
+	return d, nil
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var d, err = r.Repo.Save(ctx, deck)
+	if err != nil {
+		r.Invalidate(deck.ID)
+		return d, err
+	}
+
+	r.put(d)
+
+	return d, nil
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var d, err = r.Repo.Update(ctx, id, fn)
+	if err != nil {
+		r.Invalidate(id)
+		return d, err
+	}
+
+	r.put(d)
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	var err = r.Repo.Delete(ctx, id)
+
+	r.Invalidate(id)
+
+	return err
+}
+
+// Invalidate forgets cached decks, e.g. when another instance tells they were changed
+func (r *Repo) Invalidate(ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if e, ok := r.entries[id]; ok {
+			r.lru.Remove(e)
+			delete(r.entries, id)
+		}
+	}
+}
+
+// Stats of the cache
+func (r *Repo) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&r.hits),
+		Misses:    atomic.LoadUint64(&r.misses),
+		Evictions: atomic.LoadUint64(&r.evictions),
+		Size:      r.lru.Len(),
+	}
+}
+
+func (r *Repo) get(id string) (undeck.Deck, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var e, ok = r.entries[id]
+	if !ok {
+		return undeck.Deck{}, false
+	}
+
+	var en = e.Value.(*entry)
+
+	if r.maxAge > 0 && r.now().Sub(en.at) >= r.maxAge {
+		r.lru.Remove(e)
+		delete(r.entries, id)
+		return undeck.Deck{}, false
+	}
+
+	r.lru.MoveToFront(e)
+
+	return en.deck.Duplicate(), true
+}
+
+// put a deck in the cache unless a newer version of it is cached already, evicting the least recently used decks
+func (r *Repo) put(d undeck.Deck) {
+	if r.size <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[d.ID]; ok {
+		var en = e.Value.(*entry)
+
+		if en.deck.Version <= d.Version {
+			en.deck = d.Duplicate()
+			en.at = r.now()
+		}
+
+		r.lru.MoveToFront(e)
+
+		return
+	}
+
+	r.entries[d.ID] = r.lru.PushFront(&entry{deck: d.Duplicate(), at: r.now()})
+
+	for r.lru.Len() > r.size {
+		var e = r.lru.Back()
+
+		r.lru.Remove(e)
+		delete(r.entries, e.Value.(*entry).deck.ID)
+		atomic.AddUint64(&r.evictions, 1)
+	}
+}
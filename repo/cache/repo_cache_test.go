@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/repotest"
+	"testing"
+	"time"
+)
+
+// counting repo tells how many finds reached it
+type counting struct {
+	undeck.Repo
+	finds int
+}
+
+func (c *counting) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	c.finds++
+	return c.Repo.Find(ctx, id)
+}
+
+func draw(n int) undeck.UpdateFunc {
+	return func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(n)
+		return d, err
+	}
+}
+
+func TestRepo_Find(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		inner = &counting{Repo: memory.New()}
+		r     = New(inner, 2)
+	)
+
+	inner.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+
+	want, err := r.Find(ctx, "1")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	got, err := r.Find(ctx, "1")
+	if err != nil {
+		t.Fatalf("Find() cached error = %v", err)
+	}
+
+	internal.AssertDecksEqual(t, want, got)
+
+	// the cached deck must not be changed through a deck it handed out
+	got, _, _ = got.Draw(3)
+
+	if got, _ = r.Find(ctx, "1"); got.Remaining() != 52 {
+		t.Errorf("Find() cached deck changed: %d cards remaining, want 52", got.Remaining())
+	}
+
+	if inner.finds != 1 {
+		t.Errorf("finds reaching the repo = %d, want 1", inner.finds)
+	}
+
+	if _, err = r.Find(ctx, "2"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() missing error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if s := r.Stats(); s.Hits != 2 || s.Misses != 2 || s.Size != 1 {
+		t.Errorf("Stats() = %+v, want 2 hits, 2 misses, size 1", s)
+	}
+}
+
+func TestRepo_WriteThrough(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		inner = &counting{Repo: memory.New()}
+		r     = New(inner, 2)
+	)
+
+	if _, err := r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	want, err := r.Update(ctx, "1", draw(2))
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	got, _ := r.Find(ctx, "1")
+	stored, _ := inner.Repo.Find(ctx, "1")
+
+	internal.AssertDecksEqual(t, want, got)
+	internal.AssertDecksEqual(t, stored, got)
+
+	if inner.finds != 0 {
+		t.Errorf("finds reaching the repo = %d, want 0", inner.finds)
+	}
+
+	if err = r.Delete(ctx, "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err = r.Find(ctx, "1"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() deleted error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func TestRepo_Evict(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		inner = &counting{Repo: memory.New()}
+		r     = New(inner, 2)
+	)
+
+	for _, id := range []string{"1", "2", "3"} {
+		inner.Save(ctx, undeck.Deck{ID: id})
+	}
+
+	r.Find(ctx, "1")
+	r.Find(ctx, "2")
+	r.Find(ctx, "1")
+	r.Find(ctx, "3") // evicts 2, the least recently used
+
+	inner.finds = 0
+
+	r.Find(ctx, "1")
+	r.Find(ctx, "3")
+
+	if inner.finds != 0 {
+		t.Errorf("finds of cached decks reaching the repo = %d, want 0", inner.finds)
+	}
+
+	r.Find(ctx, "2")
+
+	if inner.finds != 1 {
+		t.Errorf("finds of an evicted deck reaching the repo = %d, want 1", inner.finds)
+	}
+
+	if s := r.Stats(); s.Evictions != 2 || s.Size != 2 {
+		t.Errorf("Stats() = %+v, want 2 evictions, size 2", s)
+	}
+}
+
+func TestRepo_Invalidate(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		inner = &counting{Repo: memory.New()}
+		r     = New(inner, 2)
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+
+	// another instance changes the deck behind the back of the cache
+	want, _ := inner.Update(ctx, "1", draw(1))
+
+	if got, _ := r.Find(ctx, "1"); got.Version == want.Version {
+		t.Fatalf("Find() should still return the cached deck")
+	}
+
+	r.Invalidate("1")
+
+	got, _ := r.Find(ctx, "1")
+	internal.AssertDecksEqual(t, want, got)
+}
+
+func TestRepo_MaxAge(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		inner = &counting{Repo: memory.New()}
+		r     = New(inner, 2, WithMaxAge(time.Minute), WithClock(func() time.Time { return now }))
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"})
+
+	now = now.Add(59 * time.Second)
+	r.Find(ctx, "1")
+
+	if inner.finds != 0 {
+		t.Errorf("finds before max age reaching the repo = %d, want 0", inner.finds)
+	}
+
+	now = now.Add(time.Second)
+	r.Find(ctx, "1")
+
+	if inner.finds != 1 {
+		t.Errorf("finds after max age reaching the repo = %d, want 1", inner.finds)
+	}
+}
+
+func TestRepo_TTL(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock = func() time.Time { return now }
+		inner = &counting{Repo: memory.New(memory.WithTTL(time.Hour, time.Hour), memory.WithClock(clock))}
+		r     = New(inner, 2, WithTTL(time.Hour), WithClock(clock))
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+
+	now = now.Add(20 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil || inner.finds != 0 {
+		t.Fatalf("Find() before half the ttl error = %v, finds reaching the repo = %d, want 0", err, inner.finds)
+	}
+
+	// reloading the deck refreshes its expiry
+	now = now.Add(20 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil || inner.finds != 1 {
+		t.Fatalf("Find() after half the ttl error = %v, finds reaching the repo = %d, want 1", err, inner.finds)
+	}
+
+	now = now.Add(50 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil {
+		t.Fatalf("Find() of a deck in use error = %v", err)
+	}
+
+	r.Save(ctx, undeck.Deck{ID: "2"}.Add(french.All()...))
+
+	now = now.Add(70 * time.Minute)
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := r.Find(ctx, id); err != undeck.ErrDeckExpired {
+			t.Errorf("Find(%s) after the ttl error = %v, want %v", id, err, undeck.ErrDeckExpired)
+		}
+	}
+}
+
+func TestRepo_Stale(t *testing.T) {
+	var r = New(memory.New(), 2)
+
+	r.put(undeck.Deck{ID: "1", Version: 3})
+	r.put(undeck.Deck{ID: "1", Version: 2})
+
+	if d, _ := r.get("1"); d.Version != 3 {
+		t.Errorf("cached version = %d, want 3", d.Version)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return New(memory.New(), 4)
+	})
+}
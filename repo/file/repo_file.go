@@ -0,0 +1,340 @@
+// Package file stores decks on disk as one binary encoded file per deck
+package file
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	decksDir = "decks"
+	lockFile = ".lock"
+	ext      = ".deck"
+)
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithIDGenerator replaces the uuid generator used for new decks
+func WithIDGenerator(fi repo.IDGenerator) Option {
+	return func(r *Repo) {
+		r.idGenerator = fi
+	}
+}
+
+// WithClock replaces the clock used for timestamps
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// New repo storing decks under dir, which is created if needed
+func New(dir string, opts ...Option) (*Repo, error) {
+	var r = &Repo{
+		mu:  internal.NewRWLock(),
+		dir: dir,
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, decksDir), 0o755); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Repo stores each deck binary encoded in a file in a directory sharded by a hash of its id.
+// Files are replaced atomically and a lock file guards against other processes using the same directory
+type Repo struct {
+	mu          *internal.RWLock
+	dir         string
+	idGenerator repo.IDGenerator
+	now         repo.Clock
+}
+
+func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
+	if err := r.mu.Lock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.Unlock()
+
+	var (
+		d       undeck.Deck
+		id, err = repo.NewID(r.idGenerator, func(id string) (bool, error) {
+			var _, err = os.Stat(r.path(id))
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+
+			return err == nil, err
+		})
+	)
+
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = id
+	d.CreatedAt = r.now()
+
+	return d, nil
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var err = r.locked(ctx, true, func() error {
+		if d, err := r.read(deck.ID); err == nil && d.Version != deck.Version {
+			return undeck.ErrVersionConflict
+		} else if err != nil && err != undeck.ErrDeckNotFound {
+			return err
+		}
+
+		deck.Version++
+		deck.AccessedAt = r.now()
+
+		return r.write(deck)
+	})
+
+	return deck, err
+}
+
+// Find a deck by id, reads do not update the access time to avoid rewriting the file
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	var (
+		d   undeck.Deck
+		err = r.locked(ctx, false, func() (err error) {
+			d, err = r.read(id)
+			return err
+		})
+	)
+
+	return d, err
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var d undeck.Deck
+
+	var err = r.locked(ctx, true, func() error {
+		var current, err = r.read(id)
+		if err != nil {
+			return err
+		}
+
+		if d, err = fn(current); err != nil {
+			return err
+		}
+
+		d.Version = current.Version + 1
+		d.AccessedAt = r.now()
+
+		return r.write(d)
+	})
+
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	return r.locked(ctx, true, func() error {
+		if check := undeck.DeleteCheck(ctx); check != nil {
+			var d, err = r.read(id)
+			if err != nil {
+				return err
+			}
+
+			if err = check(d); err != nil {
+				return err
+			}
+		}
+
+		var err = os.Remove(r.path(id))
+		if os.IsNotExist(err) {
+			return undeck.ErrDeckNotFound
+		}
+
+		return err
+	})
+}
+
+// List decks, the ids are taken from the file names so that only the decks after the cursor are read, and only until
+// the page is full
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	var (
+		page undeck.ListPage
+		size = filter.PageSize()
+	)
+
+	var err = r.locked(ctx, false, func() error {
+		var ids []string
+
+		var err = filepath.WalkDir(filepath.Join(r.dir, decksDir), func(path string, e fs.DirEntry, err error) error {
+			if err != nil || e.IsDir() || !strings.HasSuffix(path, ext) {
+				return err
+			}
+
+			if id, err := url.PathUnescape(strings.TrimSuffix(e.Name(), ext)); err == nil && id > filter.Cursor {
+				ids = append(ids, id)
+			}
+
+			return ctx.Err()
+		})
+
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+
+			d, err := r.read(id)
+			if err != nil {
+				return err
+			}
+
+			if !filter.Match(d) {
+				continue
+			}
+
+			if len(page.Decks) == size {
+				page.Next = page.Decks[size-1].ID
+				break
+			}
+
+			page.Decks = append(page.Decks, d)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return undeck.ListPage{}, err
+	}
+
+	return page, nil
+}
+
+// path of the file of a deck, sharded in two levels of directories by a hash of the id
+func (r *Repo) path(id string) string {
+	var (
+		sum = sha1.Sum([]byte(id))
+		h   = hex.EncodeToString(sum[:])
+	)
+
+	return filepath.Join(r.dir, decksDir, h[:2], h[2:4], url.PathEscape(id)+ext)
+}
+
+func (r *Repo) read(id string) (undeck.Deck, error) {
+	var (
+		d undeck.Deck
+
+		b, err = os.ReadFile(r.path(id))
+	)
+
+	if os.IsNotExist(err) {
+		return undeck.Deck{}, undeck.ErrDeckNotFound
+	} else if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	if d, err = undeck.UnmarshalDeck(b); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return d, nil
+}
+
+// write a deck to a temporary file which then replaces the deck file so that readers never see partial writes
+func (r *Repo) write(d undeck.Deck) error {
+	var (
+		path   = r.path(d.ID)
+		dir    = filepath.Dir(path)
+		b, err = d.MarshalBinary()
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		internal.Closed(tmp)
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		internal.Closed(tmp)
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// locked runs fn holding the repo lock, shared for reads or exclusive for writes, both within and across processes.
+// Waiting for the lock is given up once ctx is done
+func (r *Repo) locked(ctx context.Context, exclusive bool, fn func() error) error {
+	if exclusive {
+		if err := r.mu.Lock(ctx); err != nil {
+			return err
+		}
+
+		defer r.mu.Unlock()
+	} else {
+		if err := r.mu.RLock(ctx); err != nil {
+			return err
+		}
+
+		defer r.mu.RUnlock()
+	}
+
+	var f, err = os.OpenFile(filepath.Join(r.dir, lockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer internal.Closed(f)
+
+	if err = internal.LockFile(ctx, f, exclusive); err != nil {
+		return err
+	}
+
+	defer internal.UnlockFile(f)
+
+	return fn()
+}
@@ -0,0 +1,190 @@
+package file
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/repotest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRepo_ListSkipsRead only reads the files of the decks after the cursor and of the page asked for
+func TestRepo_ListSkipsRead(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		r, err = New(t.TempDir(), WithIDGenerator(repo.Sequential()))
+	)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		var d, _ = r.Create(ctx)
+		r.Save(ctx, d)
+	}
+
+	// unreadable decks before the cursor and after the page would fail the listing if they were read
+	for _, id := range []string{"1", "4"} {
+		if err = os.WriteFile(r.path(id), []byte("{"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := r.List(ctx, undeck.ListFilter{Cursor: "1", Limit: 1})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(page.Decks) != 1 || page.Decks[0].ID != "2" || page.Next != "2" {
+		t.Errorf("List() = %d decks, next %q, want deck 2 and next 2", len(page.Decks), page.Next)
+	}
+}
+
+// TestRepo_SharedDirectory has two repos on the same directory, as two processes would, drawing concurrently
+func TestRepo_SharedDirectory(t *testing.T) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[string]int)
+
+		ctx    = context.Background()
+		dir    = t.TempDir()
+		a, _   = New(dir)
+		b, err = New(dir)
+	)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err = a.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	for i := 0; i < 52; i++ {
+		var r = a
+		if i%2 == 1 {
+			r = b
+		}
+
+		wg.Add(1)
+
+		go func(r *Repo) {
+			defer wg.Done()
+
+			var drawn []undeck.Card
+
+			_, err := r.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+				var err error
+				d, drawn, err = d.Draw(1)
+				return d, err
+			})
+
+			if err != nil {
+				t.Errorf("Update() error = %v", err)
+				return
+			}
+
+			mu.Lock()
+			seen[drawn[0].String()]++
+			mu.Unlock()
+		}(r)
+	}
+
+	wg.Wait()
+
+	if len(seen) != 52 {
+		t.Errorf("distinct cards drawn: want = 52, got = %d", len(seen))
+	}
+
+	if d, _ := b.Find(ctx, "1"); d.Remaining() != 0 || d.Version != 53 {
+		t.Errorf("deck after draws: remaining = %d, version = %d", d.Remaining(), d.Version)
+	}
+}
+
+func TestRepo_LockTimeout(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		dir      = t.TempDir()
+		a, _     = New(dir)
+		b, err   = New(dir)
+		held     = make(chan struct{})
+		release  = make(chan struct{})
+		released = make(chan error)
+	)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err = a.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	go func() {
+		var _, err = a.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+			close(held)
+			<-release
+			return d, nil
+		})
+
+		released <- err
+	}()
+
+	<-held
+
+	// a holds the lock within its process and b finds the directory locked by another process
+	for name, r := range map[string]*Repo{"same repo": a, "other repo": b} {
+		var (
+			timeout, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+			start           = time.Now()
+		)
+
+		if _, err := r.Find(timeout, "1"); err != context.DeadlineExceeded {
+			t.Errorf("Find() in %s while locked error = %v, want %v", name, err, context.DeadlineExceeded)
+		}
+
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Find() in %s took %v, want it abandoned at the deadline", name, elapsed)
+		}
+
+		cancel()
+	}
+
+	close(release)
+
+	if err = <-released; err != nil {
+		t.Errorf("Update() holding the lock error = %v", err)
+	}
+
+	if _, err = b.Find(ctx, "1"); err != nil {
+		t.Errorf("Find() once unlocked error = %v", err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		var r, err = New(t.TempDir())
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		return r
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		var r, err = New(t.TempDir(), WithIDGenerator(gen))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		return r
+	})
+}
@@ -0,0 +1,439 @@
+// Package history keeps an append-only log of what happened to each deck, as told by the operations changing decks
+// or inferred from the changes saved to a repo. The logs are only kept in memory, whatever the decorated repo: they
+// are lost when the process stops and are not shared with other processes using the same decks, so they are no
+// record to settle disputes with
+package history
+
+import (
+	"context"
+	"errors"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrEventNotFound indicates that a deck has no event with the sequence number asked for
+	ErrEventNotFound = errors.New("event not found")
+)
+
+// EventType is what happened to a deck
+type EventType string
+
+const (
+	// Created deck with its initial order of cards, or a deck saved before its history was kept
+	Created EventType = "create"
+
+	// Shuffled remaining cards into a new order
+	Shuffled EventType = "shuffle"
+
+	// Sorted remaining cards into a new order
+	Sorted EventType = "sort"
+
+	// Drawn cards from the top of the deck
+	Drawn EventType = "draw"
+
+	// Returned drawn cards to the deck
+	Returned EventType = "return"
+
+	// Undone change, the whole deck is recorded
+	Undone EventType = "undo"
+
+	// Redone change which was undone, the whole deck is recorded
+	Redone EventType = "redo"
+
+	// Updated deck in a way that is none of the above, the whole deck is recorded
+	Updated EventType = "update"
+
+	// Deleted deck, nothing can be done with it afterwards
+	Deleted EventType = "delete"
+)
+
+// Event that happened to a deck, numbered from 1 in the order they happened.
+// Drawn events only hold the cards drawn, other events hold the remaining and drawn cards after the event
+type Event struct {
+	Seq      int       `json:"seq"`
+	Type     EventType `json:"type"`
+	Version  int       `json:"version"`
+	At       time.Time `json:"at"`
+	System   string    `json:"system,omitempty"`
+	Shuffled bool      `json:"shuffled"`
+	Hidden   bool      `json:"hidden,omitempty"`
+	Cards    []string  `json:"cards"`
+	Drawn    []string  `json:"drawn,omitempty"`
+}
+
+type eventKey struct{}
+
+// WithEvent tells the history what the change saved with ctx is, rather than inferring it from the change.
+// Draws and creations are always inferred since their events are applied differently
+func WithEvent(ctx context.Context, t EventType) context.Context {
+	return context.WithValue(ctx, eventKey{}, t)
+}
+
+// eventOf the change saved with ctx, empty if it is to be inferred
+func eventOf(ctx context.Context) EventType {
+	var t, _ = ctx.Value(eventKey{}).(EventType)
+	return t
+}
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithRetention of the history of decks, the logs of decks unchanged for longer are dropped by Prune.
+// Zero keeps them forever
+func WithRetention(d time.Duration) Option {
+	return func(r *Repo) {
+		r.retention = d
+	}
+}
+
+// WithClock replaces the clock used for the time of deletions and for retention
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// New decorates repo with a history of its decks, kept in memory
+func New(repo undeck.Repo, opts ...Option) *Repo {
+	var r = &Repo{
+		Repo: repo,
+		logs: make(map[string]*log),
+		now:  time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Repo records an event for every change saved to the decorated repo.
+// Writes to the same deck go through the decorator one at a time so that events are appended in order
+type Repo struct {
+	undeck.Repo
+
+	mu        sync.Mutex
+	logs      map[string]*log
+	retention time.Duration
+	now       repo.Clock
+}
+
+// log of a deck along with the last state it was recorded in
+type log struct {
+	mu     sync.Mutex
+	events []Event
+	last   undeck.DeckState
+
+	// pruned logs are no longer in the map, changes must be recorded in a new log
+	pruned bool
+}
+
+// Unwrap returns the decorated repo
+func (r *Repo) Unwrap() undeck.Repo {
+	return r.Repo
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var l = r.lock(repo.Key(ctx, deck.ID))
+	defer l.mu.Unlock()
+
+	var d, err = r.Repo.Save(ctx, deck)
+	if err != nil {
+		return d, err
+	}
+
+	l.append(d, eventOf(ctx))
+
+	return d, nil
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var l = r.lock(repo.Key(ctx, id))
+	defer l.mu.Unlock()
+
+	var d, err = r.Repo.Update(ctx, id, fn)
+	if err != nil {
+		return d, err
+	}
+
+	l.append(d, eventOf(ctx))
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	var l = r.lock(repo.Key(ctx, id))
+	defer l.mu.Unlock()
+
+	if err := r.Repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	if len(l.events) > 0 {
+		l.events = append(l.events, Event{
+			Seq:  len(l.events) + 1,
+			Type: Deleted,
+			At:   r.now(),
+		})
+	}
+
+	l.last = undeck.DeckState{}
+
+	return nil
+}
+
+// History of a deck, oldest event first. The history is kept after the deck is deleted, until it is pruned.
+// It fails with undeck.ErrDeckHidden if the deck was hidden when last changed, even once deleted
+func (r *Repo) History(ctx context.Context, id string) ([]Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var l, ok = r.find(repo.Key(ctx, id))
+	if !ok {
+		return nil, undeck.ErrDeckNotFound
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.hidden() {
+		return nil, undeck.ErrDeckHidden
+	}
+
+	var events = make([]Event, len(l.events))
+	copy(events, l.events)
+
+	return events, nil
+}
+
+// At rebuilds a deck as it was right after the event with sequence number seq by replaying its history
+func (r *Repo) At(ctx context.Context, id string, seq int) (undeck.Deck, error) {
+	var events, err = r.History(ctx, id)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	var s = undeck.DeckState{
+		Schema: undeck.SchemaVersion,
+		ID:     id,
+	}
+
+	if seq < 1 || seq > len(events) {
+		return undeck.Deck{}, ErrEventNotFound
+	}
+
+	for _, e := range events[:seq] {
+		s = apply(s, e)
+	}
+
+	if events[seq-1].Type == Deleted {
+		return undeck.Deck{}, undeck.ErrDeckNotFound
+	}
+
+	return s.Deck()
+}
+
+// lock the log of a deck, created on first use. The caller must unlock it
+func (r *Repo) lock(id string) *log {
+	for {
+		r.mu.Lock()
+
+		var l, ok = r.logs[id]
+		if !ok {
+			l = &log{}
+			r.logs[id] = l
+		}
+
+		r.mu.Unlock()
+
+		l.mu.Lock()
+
+		if !l.pruned {
+			return l
+		}
+
+		l.mu.Unlock()
+	}
+}
+
+// Prune the logs of decks unchanged for longer than the retention, and those left empty by failed changes
+func (r *Repo) Prune() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var now = r.now()
+
+	for id, l := range r.logs {
+		// logs being written to are not stale
+		if !l.mu.TryLock() {
+			continue
+		}
+
+		if n := len(l.events); n == 0 || r.retention > 0 && now.Sub(l.events[n-1].At) >= r.retention {
+			l.pruned = true
+			delete(r.logs, id)
+		}
+
+		l.mu.Unlock()
+	}
+}
+
+// Janitor prunes the logs every interval until ctx is done
+func (r *Repo) Janitor(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Prune()
+		}
+	}
+}
+
+func (r *Repo) find(id string) (*log, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var l, ok = r.logs[id]
+
+	return l, ok && len(l.events) > 0
+}
+
+// hidden reports whether the deck was hidden in the last state recorded before any deletion, the lock of the log
+// must be held
+func (l *log) hidden() bool {
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].Type != Deleted {
+			return l.events[i].Hidden
+		}
+	}
+
+	return false
+}
+
+// append the event which turned the last state into d, of type t if given. The lock of the log must be held
+func (l *log) append(d undeck.Deck, t EventType) {
+	var next = undeck.ToDeckState(d)
+
+	var e = diff(l.last, next)
+
+	// draws hold only the cards drawn and creations start the log, so only other events take the type given
+	if t != "" && t != Drawn && t != Created && l.last.Schema != 0 {
+		if e.Type == Drawn {
+			e.Cards, e.Drawn = next.Cards, next.Drawn
+		}
+
+		e.Type = t
+	}
+
+	e.Seq = len(l.events) + 1
+	l.events = append(l.events, e)
+	l.last = next
+}
+
+// diff infers the event which turned prev into next, prev is empty for a deck not seen before
+func diff(prev, next undeck.DeckState) Event {
+	var e = Event{
+		Version:  next.Version,
+		At:       next.AccessedAt,
+		Shuffled: next.Shuffled,
+		Hidden:   next.Hidden,
+		Cards:    next.Cards,
+		Drawn:    next.Drawn,
+	}
+
+	var n = len(next.Drawn) - len(prev.Drawn)
+
+	switch {
+	case prev.Schema == 0:
+		e.Type = Created
+		e.System = next.System
+	case n > 0 && hasPrefix(next.Drawn, prev.Drawn) && hasPrefix(prev.Cards, next.Drawn[len(prev.Drawn):]) && equal(prev.Cards[n:], next.Cards):
+		e.Type = Drawn
+		e.Cards = next.Drawn[len(prev.Drawn):]
+		e.Drawn = nil
+	case n < 0 && hasPrefix(prev.Drawn, next.Drawn):
+		e.Type = Returned
+	case n == 0 && equal(prev.Drawn, next.Drawn) && sameCards(prev.Cards, next.Cards) && next.Shuffled && (!prev.Shuffled || !equal(prev.Cards, next.Cards)):
+		e.Type = Shuffled
+	case n == 0 && equal(prev.Drawn, next.Drawn) && sameCards(prev.Cards, next.Cards) && !equal(prev.Cards, next.Cards):
+		e.Type = Sorted
+	default:
+		e.Type = Updated
+	}
+
+	return e
+}
+
+// apply an event to the state of a deck
+func apply(s undeck.DeckState, e Event) undeck.DeckState {
+	switch e.Type {
+	case Created:
+		s.System = e.System
+		s.CreatedAt = e.At
+		s.Cards = e.Cards
+		s.Drawn = e.Drawn
+	case Drawn:
+		s.Drawn = append(append([]string(nil), s.Drawn...), e.Cards...)
+		s.Cards = s.Cards[len(e.Cards):]
+	default:
+		s.Cards = e.Cards
+		s.Drawn = e.Drawn
+	}
+
+	s.Shuffled = e.Shuffled
+	s.Hidden = e.Hidden
+	s.Version = e.Version
+	s.AccessedAt = e.At
+
+	return s
+}
+
+// hasPrefix reports whether s begins with prefix
+func hasPrefix(s, prefix []string) bool {
+	return len(s) >= len(prefix) && equal(s[:len(prefix)], prefix)
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameCards reports whether a and b hold the same cards in any order
+func sameCards(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	var count = make(map[string]int)
+
+	for i := range a {
+		count[a[i]]++
+		count[b[i]]--
+	}
+
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+
+	return true
+}
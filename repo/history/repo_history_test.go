@@ -0,0 +1,238 @@
+package history
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/repotest"
+	"testing"
+	"time"
+)
+
+func TestRepo_History(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		r      = New(memory.New(memory.WithIDGenerator(repo.Sequential())))
+		states []undeck.Deck
+	)
+
+	d, _ := r.Create(ctx)
+	d.System = french.System
+
+	d, err := r.Save(ctx, d.Add(french.All()[:10]...))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	states = append(states, d)
+
+	for _, fn := range []undeck.UpdateFunc{
+		func(d undeck.Deck) (undeck.Deck, error) {
+			return d.Shuffle(), nil
+		},
+		func(d undeck.Deck) (undeck.Deck, error) {
+			d, _, err := d.Draw(2)
+			return d, err
+		},
+		func(d undeck.Deck) (undeck.Deck, error) {
+			return d.Sort(french.SuitRankOrder), nil
+		},
+		func(d undeck.Deck) (undeck.Deck, error) {
+			var drawn = d.Drawn()
+			return d.Add(drawn[1:]...).WithDrawn(drawn[:1]...), nil
+		},
+	} {
+		if d, err = r.Update(ctx, d.ID, fn); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		states = append(states, d)
+	}
+
+	if err = r.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	events, err := r.History(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+
+	var want = []EventType{Created, Shuffled, Drawn, Sorted, Returned, Deleted}
+
+	if len(events) != len(want) {
+		t.Fatalf("History() = %d events, want %d", len(events), len(want))
+	}
+
+	for i := range want {
+		if events[i].Type != want[i] || events[i].Seq != i+1 {
+			t.Errorf("event %d = %s #%d, want %s #%d", i, events[i].Type, events[i].Seq, want[i], i+1)
+		}
+	}
+
+	if got := events[2].Cards; len(got) != 2 {
+		t.Errorf("draw event cards = %v, want the 2 cards drawn", got)
+	}
+
+	for i, state := range states {
+		var got, err = r.At(ctx, d.ID, i+1)
+		if err != nil {
+			t.Fatalf("At(%d) error = %v", i+1, err)
+		}
+
+		internal.AssertDecksEqual(t, state, got)
+		internal.AssertCardSlicesEqual(t, state.Drawn(), got.Drawn())
+	}
+
+	if _, err = r.At(ctx, d.ID, len(events)); err != undeck.ErrDeckNotFound {
+		t.Errorf("At() deleted error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if _, err = r.At(ctx, d.ID, len(events)+1); err != ErrEventNotFound {
+		t.Errorf("At() past the end error = %v, want %v", err, ErrEventNotFound)
+	}
+
+	if _, err = r.History(ctx, "unknown"); err != undeck.ErrDeckNotFound {
+		t.Errorf("History() unknown deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func TestRepo_WithEvent(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = New(memory.New())
+	)
+
+	d, err := r.Save(WithEvent(ctx, Sorted), undeck.Deck{ID: "1", System: french.System}.Add(french.All()[:4]...))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	var states = []undeck.Deck{d}
+
+	for _, u := range []struct {
+		event EventType
+		fn    undeck.UpdateFunc
+	}{
+		{event: Drawn, fn: func(d undeck.Deck) (undeck.Deck, error) {
+			d, _, err := d.Draw(2)
+			return d, err
+		}},
+		{event: Sorted, fn: func(d undeck.Deck) (undeck.Deck, error) {
+			return d.SortPile(undeck.PileDrawn, french.SuitRankOrder)
+		}},
+		// going back to the first state looks like returning the drawn cards
+		{event: Undone, fn: func(undeck.Deck) (undeck.Deck, error) {
+			return states[0].Duplicate(), nil
+		}},
+		// going forward again looks like a draw
+		{event: Redone, fn: func(undeck.Deck) (undeck.Deck, error) {
+			return states[2].Duplicate(), nil
+		}},
+	} {
+		if d, err = r.Update(WithEvent(ctx, u.event), d.ID, u.fn); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		states = append(states, d)
+	}
+
+	events, err := r.History(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+
+	var want = []EventType{Created, Drawn, Sorted, Undone, Redone}
+
+	if len(events) != len(want) {
+		t.Fatalf("History() = %d events, want %d", len(events), len(want))
+	}
+
+	for i := range want {
+		if events[i].Type != want[i] {
+			t.Errorf("event %d = %s, want %s", i, events[i].Type, want[i])
+		}
+
+		var got, err = r.At(ctx, d.ID, i+1)
+		if err != nil {
+			t.Fatalf("At(%d) error = %v", i+1, err)
+		}
+
+		internal.AssertDecksEqual(t, states[i], got)
+		internal.AssertCardSlicesEqual(t, states[i].Drawn(), got.Drawn())
+	}
+}
+
+func TestRepo_Hidden(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = New(memory.New())
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1", System: french.System, Hidden: true}.Add(french.All()[:4]...))
+
+	if _, err := r.History(ctx, "1"); err != undeck.ErrDeckHidden {
+		t.Errorf("History() hidden deck error = %v, want %v", err, undeck.ErrDeckHidden)
+	}
+
+	r.Delete(ctx, "1")
+
+	if _, err := r.History(ctx, "1"); err != undeck.ErrDeckHidden {
+		t.Errorf("History() deleted hidden deck error = %v, want %v", err, undeck.ErrDeckHidden)
+	}
+
+	if _, err := r.At(ctx, "1", 1); err != undeck.ErrDeckHidden {
+		t.Errorf("At() deleted hidden deck error = %v, want %v", err, undeck.ErrDeckHidden)
+	}
+}
+
+func TestRepo_Prune(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock = func() time.Time { return now }
+		r     = New(memory.New(memory.WithClock(clock)), WithRetention(time.Hour), WithClock(clock))
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1", System: french.System}.Add(french.All()[:4]...))
+	r.Save(ctx, undeck.Deck{ID: "2", System: french.System}.Add(french.All()[:4]...))
+
+	// failed changes leave an empty log behind
+	r.Update(ctx, "3", func(d undeck.Deck) (undeck.Deck, error) { return d, nil })
+
+	now = now.Add(30 * time.Minute)
+	r.Delete(ctx, "2")
+
+	now = now.Add(30 * time.Minute)
+	r.Prune()
+
+	if _, err := r.History(ctx, "1"); err != undeck.ErrDeckNotFound {
+		t.Errorf("History() unchanged for the retention error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if events, err := r.History(ctx, "2"); err != nil || len(events) != 2 {
+		t.Errorf("History() deleted within the retention = %d events (%v), want 2", len(events), err)
+	}
+
+	if len(r.logs) != 1 {
+		t.Errorf("logs = %d, want only the one of deck 2", len(r.logs))
+	}
+
+	// changes after the log was pruned start a new one
+	if _, err := r.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) { return d.Shuffle(), nil }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if events, err := r.History(ctx, "1"); err != nil || len(events) != 1 || events[0].Type != Created {
+		t.Errorf("History() after pruning = %v (%v), want a new log", events, err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return New(memory.New())
+	})
+}
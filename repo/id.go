@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"crypto/rand"
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"go.fluxy.net/undeck"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxIDAttempts is how many ids NewID generates before giving up with undeck.ErrIDCollision
+const MaxIDAttempts = 10
+
+// IDGenerator is a function that can be used by repo to generate ids
+type IDGenerator func() string
+
+// Sequential returns the given ids in order, then ids from a sequence counting on from the number of ids given
+func Sequential(ids ...string) IDGenerator {
+	var (
+		mu sync.Mutex
+		i  int
+	)
+
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		i++
+
+		if i <= len(ids) {
+			return ids[i-1]
+		}
+
+		return strconv.Itoa(i)
+	}
+}
+
+// UUID returns random version 4 uuids, what repos use when no generator is set
+func UUID() IDGenerator {
+	return uuid.NewString
+}
+
+// ULID returns ulids, which sort in the order they were made
+func ULID() IDGenerator {
+	return func() string {
+		return ulid.Make().String()
+	}
+}
+
+// shortAlphabet is the lowercase crockford base32 alphabet, leaving out i, l, o and u which are easily misread
+const shortAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// Short returns random codes of n characters which are easy to read out loud, e.g. "k7m2qx"
+func Short(n int) IDGenerator {
+	return func() string {
+		var b = make([]byte, n)
+
+		for i := range b {
+			b[i] = shortAlphabet[random(len(shortAlphabet))]
+		}
+
+		return string(b)
+	}
+}
+
+var (
+	adjectives = strings.Fields(`
+		able bold brave bright calm clever cosy crisp daring eager fair fancy fierce gentle glad grand
+		happy hardy honest jolly keen kind lively lucky merry mighty neat nimble noble plucky polite proud
+		quick quiet rapid ready regal rosy rusty shiny shy silly sleek smart snowy sober spry steady
+		stout sunny swift tidy tiny tough trusty vivid warm wary wild wise witty young zany zesty`)
+
+	animals = strings.Fields(`
+		badger bear beaver bison camel cat cobra crane crow deer dingo dog dove eagle eel falcon
+		ferret finch fox frog gecko goat goose hare hawk heron horse hyena ibis jackal koala lemur
+		lion llama lynx mole moose mouse newt otter owl panda parrot pike puma quail rabbit raven
+		seal shark sheep skunk sloth snail stork swan tiger toad trout viper walrus wolf wombat yak`)
+)
+
+// Words returns codes made of two different adjectives, an animal and a number below 10000, e.g.
+// "brave-quiet-otter-4217". They are easy to read out loud but come from about 2.6 billion codes, 31 bits or about
+// as many as Short(6), against 122 random bits for uuids: a client trying codes at random finds the decks of others
+// long before it would with uuids, so they are for decks which are not secret or are kept apart by tenants
+func Words() IDGenerator {
+	return func() string {
+		var (
+			first  = random(len(adjectives))
+			second = random(len(adjectives) - 1)
+		)
+
+		if second >= first {
+			second++
+		}
+
+		return adjectives[first] + "-" + adjectives[second] + "-" + animals[random(len(animals))] + "-" + strconv.Itoa(random(10000))
+	}
+}
+
+// Prefixed puts prefix in front of the ids of gen, e.g. to tell the decks of different deployments apart
+func Prefixed(prefix string, gen IDGenerator) IDGenerator {
+	return func() string {
+		return prefix + gen()
+	}
+}
+
+// NewID generates ids with gen, or uuids if gen is nil, until one is not taken. It fails with undeck.ErrIDCollision
+// after MaxIDAttempts ids were taken
+func NewID(gen IDGenerator, taken func(id string) (bool, error)) (string, error) {
+	if gen == nil {
+		gen = UUID()
+	}
+
+	for i := 0; i < MaxIDAttempts; i++ {
+		var id = gen()
+
+		if t, err := taken(id); err != nil {
+			return "", err
+		} else if !t {
+			return id, nil
+		}
+	}
+
+	return "", undeck.ErrIDCollision
+}
+
+// random number in [0, n) from a cryptographically secure source so that codes cannot be guessed
+func random(n int) int {
+	var i, err = rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+
+	return int(i.Int64())
+}
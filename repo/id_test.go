@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"errors"
+	"go.fluxy.net/undeck"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSequential(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{name: "none", ids: nil, want: []string{"1", "2", "3"}},
+		{name: "given", ids: []string{"a", "b"}, want: []string{"a", "b", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gen = Sequential(tt.ids...)
+
+			for _, want := range tt.want {
+				if got := gen(); got != want {
+					t.Errorf("Sequential() = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  IDGenerator
+		want *regexp.Regexp
+	}{
+		{name: "uuid", gen: UUID(), want: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+		{name: "ulid", gen: ULID(), want: regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)},
+		{name: "short", gen: Short(6), want: regexp.MustCompile(`^[0-9a-hjkmnp-tv-z]{6}$`)},
+		{name: "words", gen: Words(), want: regexp.MustCompile(`^[a-z]+-[a-z]+-[a-z]+-[0-9]{1,4}$`)},
+		{name: "prefixed", gen: Prefixed("eu-", Short(4)), want: regexp.MustCompile(`^eu-[0-9a-hjkmnp-tv-z]{4}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen = make(map[string]bool)
+
+			for i := 0; i < 100; i++ {
+				var id = tt.gen()
+
+				if !tt.want.MatchString(id) {
+					t.Fatalf("id %q does not match %s", id, tt.want)
+				}
+
+				seen[id] = true
+			}
+
+			if len(seen) < 90 {
+				t.Errorf("%d distinct ids out of 100, want them random", len(seen))
+			}
+		})
+	}
+}
+
+func TestULID_Sorted(t *testing.T) {
+	var (
+		gen = ULID()
+		ids = make([]string, 1000)
+	)
+
+	for i := range ids {
+		ids[i] = gen()
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ULID() ids are not made in sorted order")
+	}
+}
+
+func TestWords_Lists(t *testing.T) {
+	for _, words := range [][]string{adjectives, animals} {
+		var seen = make(map[string]bool)
+
+		for _, w := range words {
+			if seen[w] {
+				t.Errorf("%q listed twice", w)
+			}
+
+			seen[w] = true
+		}
+
+		if len(words) != 64 {
+			t.Errorf("%d words starting with %q, want 64", len(words), words[0])
+		}
+	}
+
+	var gen = Words()
+
+	for i := 0; i < 1000; i++ {
+		if parts := strings.Split(gen(), "-"); parts[0] == parts[1] {
+			t.Fatalf("id %q repeats its adjective", strings.Join(parts, "-"))
+		}
+	}
+}
+
+func TestNewID(t *testing.T) {
+	var errTaken = errors.New("taken failed")
+
+	tests := []struct {
+		name    string
+		gen     IDGenerator
+		taken   map[string]bool
+		err     error
+		want    string
+		wantErr error
+	}{
+		{name: "free", gen: Sequential(), want: "1"},
+		{name: "retry", gen: Sequential(), taken: map[string]bool{"1": true, "2": true}, want: "3"},
+		{name: "give up", gen: func() string { return "1" }, taken: map[string]bool{"1": true}, wantErr: undeck.ErrIDCollision},
+		{name: "error", gen: Sequential(), err: errTaken, wantErr: errTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = NewID(tt.gen, func(id string) (bool, error) {
+				return tt.taken[id], tt.err
+			})
+
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("NewID() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if id, _ := NewID(nil, func(string) (bool, error) { return false, nil }); strings.Count(id, "-") != 4 {
+		t.Errorf("NewID() without generator = %q, want a uuid", id)
+	}
+}
@@ -2,77 +2,357 @@ package memory
 
 import (
 	"context"
-	"github.com/google/uuid"
 	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/internal"
 	"go.fluxy.net/undeck/repo"
+	"sort"
+	"time"
 )
 
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithTTL expires decks not accessed for ttl, expired decks are reported as such for grace before being forgotten
+func WithTTL(ttl, grace time.Duration) Option {
+	return func(r *Repo) {
+		r.ttl = ttl
+		r.grace = grace
+	}
+}
+
+// WithIDGenerator replaces the uuid generator used for new decks
+func WithIDGenerator(fi repo.IDGenerator) Option {
+	return func(r *Repo) {
+		r.idGenerator = fi
+	}
+}
+
+// WithClock replaces the clock used for timestamps and expiry
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
 // New repo instance
-func New() undeck.Repo {
-	return &Repo{
-		decks: make(map[string]undeck.Deck),
+func New(opts ...Option) *Repo {
+	var r = &Repo{
+		mu:      internal.NewRWLock(),
+		decks:   make(map[string]undeck.Deck),
+		expired: make(map[string]time.Time),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
 }
 
 // NewWith instantiates a repo with custom decks and id generator
 func NewWith(fi repo.IDGenerator, shufflerFunc undeck.ShufflerFunc, decks ...undeck.Deck) undeck.Repo {
-	var d = make(map[string]undeck.Deck, len(decks))
+	var r = New()
 
 	for i := range decks {
-		d[decks[i].ID] = decks[i]
+		r.decks[decks[i].ID] = decks[i]
 	}
 
-	return &Repo{
-		decks:        d,
-		idGenerator:  fi,
-		shufflerFunc: shufflerFunc,
-	}
+	r.idGenerator = fi
+	r.shufflerFunc = shufflerFunc
+
+	return r
 }
 
-// Repo for in memory persistence
+// Repo for in memory persistence, safe for concurrent use
 type Repo struct {
+	mu           *internal.RWLock
 	decks        map[string]undeck.Deck
+	expired      map[string]time.Time
 	idGenerator  repo.IDGenerator
 	shufflerFunc undeck.ShufflerFunc
+	now          repo.Clock
+	ttl          time.Duration
+	grace        time.Duration
+	wal          *wal
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
 }
 
 func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
-	var d undeck.Deck
+	if err := r.mu.Lock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.Unlock()
 
-	if r.idGenerator != nil {
-		d.ID = r.idGenerator()
-	} else if u, err := uuid.NewRandom(); err != nil {
+	var (
+		d       undeck.Deck
+		id, err = repo.NewID(r.idGenerator, func(id string) (bool, error) {
+			var _, err = r.get(id)
+			return err != undeck.ErrDeckNotFound, nil
+		})
+	)
+
+	if err != nil {
 		return d, err
-	} else {
-		d.ID = u.String()
 	}
 
+	d.ID = id
 	d.Shuffler = r.shufflerFunc
+	d.CreatedAt = r.now()
 
 	return d, nil
 }
 
 func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	if err := r.mu.Lock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.Unlock()
+
+	if d, ok := r.decks[deck.ID]; ok && d.Version != deck.Version {
+		return deck, undeck.ErrVersionConflict
+	}
+
+	deck.Version++
+	deck.AccessedAt = r.now()
+
+	if err := r.wal.put(deck); err != nil {
+		return undeck.Deck{}, err
+	}
+
 	r.decks[deck.ID] = deck
+	delete(r.expired, deck.ID)
 
 	return deck, nil
 }
 
+// Find a deck by id, refreshing its access time unless ctx is from repo.WithPeek
 func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	if repo.Peek(ctx) {
+		return r.peek(ctx, id)
+	}
+
+	if err := r.mu.Lock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.Unlock()
+
+	var d, err = r.get(id)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	d.AccessedAt = r.now()
+	r.decks[id] = d
+
+	return d.Duplicate(), nil
+}
+
+// peek at a deck without refreshing its access time
+func (r *Repo) peek(ctx context.Context, id string) (undeck.Deck, error) {
+	if err := r.mu.RLock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.RUnlock()
+
+	var d, err = r.get(id)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return d.Duplicate(), nil
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	if err := r.mu.Lock(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer r.mu.Unlock()
+
+	var d, err = r.get(id)
+	if err != nil {
+		return d, err
+	}
+
+	var version = d.Version
+
+	d, err = fn(d.Duplicate())
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	d.Version = version + 1
+	d.AccessedAt = r.now()
+
+	if err = r.wal.put(d); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	r.decks[id] = d
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	if err := r.mu.Lock(ctx); err != nil {
+		return err
+	}
+
+	defer r.mu.Unlock()
+
+	if d, err := r.get(id); err != nil {
+		return err
+	} else if check := undeck.DeleteCheck(ctx); check != nil {
+		if err = check(d.Duplicate()); err != nil {
+			return err
+		}
+	}
+
+	if err := r.wal.delete(id); err != nil {
+		return err
+	}
+
+	delete(r.decks, id)
+
+	return nil
+}
+
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	if err := r.mu.RLock(ctx); err != nil {
+		return undeck.ListPage{}, err
+	}
+
+	defer r.mu.RUnlock()
+
 	var (
+		page undeck.ListPage
+		ids  []string
+		size = filter.PageSize()
+		now  = r.now()
+	)
+
+	for id := range r.decks {
+		if id > filter.Cursor {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var d = r.decks[id]
+
+		if r.isExpired(d, now) || !filter.Match(d) {
+			continue
+		}
+
+		if len(page.Decks) == size {
+			page.Next = page.Decks[size-1].ID
+			break
+		}
+
+		page.Decks = append(page.Decks, d.Duplicate())
+	}
+
+	return page, nil
+}
+
+// get a deck which has not expired, the lock must be held
+func (r *Repo) get(id string) (undeck.Deck, error) {
+	var (
+		now   = r.now()
 		d, ok = r.decks[id]
-		d2    undeck.Deck
 	)
 
-	if !ok {
-		return d2, undeck.ErrDeckNotFound
+	if ok && !r.isExpired(d, now) {
+		return d, nil
+	}
+
+	if ok {
+		return undeck.Deck{}, undeck.ErrDeckExpired
+	}
+
+	if at, ok := r.expired[id]; ok && now.Sub(at) < r.grace {
+		return undeck.Deck{}, undeck.ErrDeckExpired
+	}
+
+	return undeck.Deck{}, undeck.ErrDeckNotFound
+}
+
+func (r *Repo) isExpired(d undeck.Deck, now time.Time) bool {
+	var last = d.LastAccess()
+
+	return r.ttl > 0 && !last.IsZero() && now.Sub(last) >= r.ttl
+}
+
+// Evict expired decks and forget those evicted for longer than the grace period. Evictions are written to the
+// write-ahead log, decks whose eviction fails to be written are kept until the next one and the first error is
+// returned
+func (r *Repo) Evict() error {
+	r.mu.Lock(context.Background())
+	defer r.mu.Unlock()
+
+	var (
+		err error
+		now = r.now()
+	)
+
+	for id, d := range r.decks {
+		if !r.isExpired(d, now) {
+			continue
+		}
+
+		if werr := r.wal.delete(id); werr != nil {
+			if err == nil {
+				err = werr
+			}
+
+			continue
+		}
+
+		delete(r.decks, id)
+		r.expired[id] = now
+	}
+
+	for id, at := range r.expired {
+		if now.Sub(at) >= r.grace {
+			delete(r.expired, id)
+		}
 	}
 
-	d2 = d.Duplicate()
+	return err
+}
+
+// Janitor evicts expired decks every interval until ctx is done, evictions which fail are retried the next time
+func (r *Repo) Janitor(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
 
-	return d2, nil
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Evict()
+		}
+	}
 }
 
-func (r Repo) Dump() map[string]undeck.Deck {
-	return r.decks
+func (r *Repo) Dump() map[string]undeck.Deck {
+	r.mu.RLock(context.Background())
+	defer r.mu.RUnlock()
+
+	var decks = make(map[string]undeck.Deck, len(r.decks))
+
+	for id, d := range r.decks {
+		decks[id] = d
+	}
+
+	return decks
 }
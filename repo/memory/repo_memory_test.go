@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/repotest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestRepo_Expiry(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		clock = &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+		r     = New(WithTTL(time.Hour, 10*time.Minute), WithClock(clock.Now), WithIDGenerator(repo.Sequential()))
+	)
+
+	for i := 0; i < 2; i++ {
+		var d, _ = r.Create(ctx)
+		if _, err := r.Save(ctx, d); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	clock.Advance(50 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil {
+		t.Fatalf("Find() before ttl error = %v", err)
+	}
+
+	if _, err := r.Find(repo.WithPeek(ctx), "2"); err != nil {
+		t.Fatalf("Find() peeking before ttl error = %v", err)
+	}
+
+	clock.Advance(20 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil {
+		t.Errorf("Find() accessed deck error = %v", err)
+	}
+
+	if _, err := r.Find(ctx, "2"); err != undeck.ErrDeckExpired {
+		t.Errorf("Find() peeked at deck error = %v, want %v", err, undeck.ErrDeckExpired)
+	}
+
+	r.Evict()
+
+	if got := len(r.Dump()); got != 1 {
+		t.Errorf("decks after eviction: want = 1, got = %d", got)
+	}
+
+	if _, err := r.Update(ctx, "2", func(d undeck.Deck) (undeck.Deck, error) { return d, nil }); err != undeck.ErrDeckExpired {
+		t.Errorf("Update() evicted deck error = %v, want %v", err, undeck.ErrDeckExpired)
+	}
+
+	clock.Advance(10 * time.Minute)
+	r.Evict()
+
+	if _, err := r.Find(ctx, "2"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() after grace error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func TestRepo_Janitor(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		clock       = &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+		r           = New(WithTTL(time.Hour, time.Hour), WithClock(clock.Now))
+		done        = make(chan struct{})
+	)
+
+	r.decks["1"] = undeck.Deck{ID: "1", CreatedAt: clock.Now().Add(-2 * time.Hour)}
+
+	go func() {
+		r.Janitor(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	var deadline = time.After(time.Second)
+
+	for len(r.Dump()) != 0 {
+		select {
+		case <-deadline:
+			t.Fatal("janitor did not evict the expired deck")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop when its context was cancelled")
+	}
+}
+
+func TestRepo_LockTimeout(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		r        = New()
+		held     = make(chan struct{})
+		release  = make(chan struct{})
+		released = make(chan error)
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"})
+
+	go func() {
+		var _, err = r.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+			close(held)
+			<-release
+			return d, nil
+		})
+
+		released <- err
+	}()
+
+	<-held
+
+	var timeout, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Find(timeout, "1"); err != context.DeadlineExceeded {
+		t.Errorf("Find() while locked error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if _, err := r.List(timeout, undeck.ListFilter{}); err != context.DeadlineExceeded {
+		t.Errorf("List() while locked error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+
+	if err := <-released; err != nil {
+		t.Errorf("Update() holding the lock error = %v", err)
+	}
+
+	if d, err := r.Find(ctx, "1"); err != nil || d.Version != 2 {
+		t.Errorf("Find() once unlocked = version %d (%v), want 2", d.Version, err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return New()
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		return New(WithIDGenerator(gen))
+	})
+}
@@ -0,0 +1,509 @@
+package memory
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/internal"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncPolicy tells how often the write-ahead log is flushed to disk. Every policy survives the process being killed,
+// they differ in how many changes a power loss or an operating system crash may lose
+type SyncPolicy int
+
+const (
+	// SyncInterval flushes the log every interval set with WithSync, losing at most that much on power loss
+	SyncInterval SyncPolicy = iota
+
+	// SyncAlways flushes the log before every change returns, losing nothing but making writes much slower
+	SyncAlways
+
+	// SyncNever leaves flushing to the operating system
+	SyncNever
+)
+
+// DefaultSyncInterval is how often the log is flushed with SyncInterval unless set with WithSync
+const DefaultSyncInterval = time.Second
+
+const (
+	opPut    byte = 1 // the deck binary encoded follows
+	opDelete byte = 2 // the id of the deck follows
+
+	// recordHeader is the length of the payload and its checksum, both 4 bytes little endian
+	recordHeader = 8
+
+	walPrefix      = "wal-"
+	snapshotPrefix = "snapshot-"
+	fileExt        = ".log"
+
+	// lockFile is held by the process which opened the directory
+	lockFile = ".lock"
+)
+
+// errTorn means a record was cut short or does not match its checksum, e.g. when the process died writing it
+var errTorn = errors.New("torn record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WithSync sets how often the write-ahead log of a repo made with Open is flushed to disk, interval only
+// applies to SyncInterval
+func WithSync(policy SyncPolicy, interval time.Duration) Option {
+	return func(r *Repo) {
+		r.syncPolicy = policy
+		r.syncInterval = interval
+	}
+}
+
+// Open a repo kept in memory which survives restarts. Every change is appended to a write-ahead log in dir before
+// it is applied, and Snapshot writes all decks at once so that the log can be dropped. The decks of a previous run
+// are recovered from the latest snapshot and the logs written after it. Only one process at a time may open dir, the
+// others fail with internal.ErrFileLocked.
+// Access times refreshed by Find are only kept by snapshots, so the TTL of recovered decks runs from their last
+// change or the last snapshot. Close the repo to flush the log
+func Open(dir string, opts ...Option) (*Repo, error) {
+	var r = New(append([]Option{WithSync(SyncInterval, DefaultSyncInterval)}, opts...)...)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// another process appending to the same logs would corrupt them
+	var lock, err = os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = internal.TryLockFile(lock, true); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	gen, err := r.recover(dir)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	r.wal = &wal{dir: dir, policy: r.syncPolicy, done: make(chan struct{}), lock: lock}
+
+	if err = r.wal.rotate(gen + 1); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	if r.syncPolicy == SyncInterval && r.syncInterval > 0 {
+		go r.wal.flusher(r.syncInterval)
+	}
+
+	return r, nil
+}
+
+// Snapshot writes every deck to disk and drops the logs of the changes it holds. Writes are only blocked while the
+// decks are copied, not while they are written
+func (r *Repo) Snapshot() error {
+	if r.wal == nil {
+		return nil
+	}
+
+	r.wal.snapshotting.Lock()
+	defer r.wal.snapshotting.Unlock()
+
+	r.mu.Lock(context.Background())
+
+	var (
+		decks = make([]undeck.Deck, 0, len(r.decks))
+		gen   = r.wal.gen
+	)
+
+	for _, d := range r.decks {
+		decks = append(decks, d)
+	}
+
+	// changes from now on go to the next log, which the snapshot does not hold
+	var err = r.wal.rotate(gen + 1)
+
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return r.wal.snapshot(gen, decks)
+}
+
+// Snapshots takes a snapshot every interval until ctx is done or a snapshot fails
+func (r *Repo) Snapshots(ctx context.Context, interval time.Duration) error {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Snapshot(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close flushes and closes the write-ahead log, the repo must not be changed afterwards
+func (r *Repo) Close() error {
+	if r.wal == nil {
+		return nil
+	}
+
+	return r.wal.close()
+}
+
+// recover the decks of the latest snapshot and the logs written after it, returning the last generation found
+func (r *Repo) recover(dir string) (uint64, error) {
+	var snapshots, logs, err = generations(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var last uint64
+
+	if len(snapshots) > 0 {
+		last = snapshots[len(snapshots)-1]
+
+		if err = r.replay(filepath.Join(dir, name(snapshotPrefix, last)), false); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, gen := range logs {
+		if gen <= last {
+			continue
+		}
+
+		if err = r.replay(filepath.Join(dir, name(walPrefix, gen)), true); err != nil {
+			return 0, err
+		}
+
+		last = gen
+	}
+
+	return last, nil
+}
+
+// replay the records of a file into the decks. A log may end with a torn record, which is cut off
+func (r *Repo) replay(path string, log bool) error {
+	var f, err = os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer internal.Closed(f)
+
+	var offset int64
+
+	for {
+		var op, payload, n, err = readRecord(f)
+
+		switch {
+		case err == io.EOF:
+			return nil
+		case err == errTorn && log:
+			return f.Truncate(offset)
+		case err != nil:
+			return fmt.Errorf("%s at %d: %w", filepath.Base(path), offset, err)
+		}
+
+		offset += n
+
+		switch op {
+		case opPut:
+			var d undeck.Deck
+			if err = d.UnmarshalBinary(payload); err != nil {
+				return fmt.Errorf("%s at %d: %w", filepath.Base(path), offset, err)
+			}
+
+			d.Shuffler = r.shufflerFunc
+			r.decks[d.ID] = d
+		case opDelete:
+			delete(r.decks, string(payload))
+		}
+	}
+}
+
+// wal is the write-ahead log of a repo, a nil wal logs nothing
+type wal struct {
+	mu           sync.Mutex
+	snapshotting sync.Mutex
+	dir          string
+	policy       SyncPolicy
+	f            *os.File
+	gen          uint64
+	dirty        bool
+	done         chan struct{}
+	lock         *os.File
+}
+
+// put logs that a deck was saved
+func (w *wal) put(d undeck.Deck) error {
+	if w == nil {
+		return nil
+	}
+
+	var b, err = d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return w.append(opPut, b)
+}
+
+// delete logs that a deck was deleted
+func (w *wal) delete(id string) error {
+	if w == nil {
+		return nil
+	}
+
+	return w.append(opDelete, []byte(id))
+}
+
+func (w *wal) append(op byte, payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+
+	if _, err := w.f.Write(record(op, payload)); err != nil {
+		return err
+	}
+
+	if w.policy == SyncAlways {
+		return w.f.Sync()
+	}
+
+	w.dirty = true
+
+	return nil
+}
+
+// rotate to a new log of generation gen, flushing the current one
+func (w *wal) rotate(gen uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var f, err = os.OpenFile(filepath.Join(w.dir, name(walPrefix, gen)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if w.f != nil {
+		if err = w.f.Sync(); err == nil {
+			err = w.f.Close()
+		}
+
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	w.f, w.gen, w.dirty = f, gen, false
+
+	return syncDir(w.dir)
+}
+
+// snapshot writes decks as the snapshot of generation gen, then removes the files it replaces
+func (w *wal) snapshot(gen uint64, decks []undeck.Deck) error {
+	var tmp, err = os.CreateTemp(w.dir, snapshotPrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	for _, d := range decks {
+		var b []byte
+
+		if b, err = d.MarshalBinary(); err == nil {
+			_, err = tmp.Write(record(opPut, b))
+		}
+
+		if err != nil {
+			internal.Closed(tmp)
+			return err
+		}
+	}
+
+	if err = tmp.Sync(); err != nil {
+		internal.Closed(tmp)
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), filepath.Join(w.dir, name(snapshotPrefix, gen))); err != nil {
+		return err
+	}
+
+	if err = syncDir(w.dir); err != nil {
+		return err
+	}
+
+	snapshots, logs, err := generations(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range snapshots {
+		if g < gen {
+			os.Remove(filepath.Join(w.dir, name(snapshotPrefix, g)))
+		}
+	}
+
+	for _, g := range logs {
+		if g <= gen {
+			os.Remove(filepath.Join(w.dir, name(walPrefix, g)))
+		}
+	}
+
+	return nil
+}
+
+// flusher flushes the log every interval until it is closed
+func (w *wal) flusher(interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+
+			if w.dirty && w.f != nil {
+				w.f.Sync()
+				w.dirty = false
+			}
+
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+
+	close(w.done)
+
+	var err = w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+
+	w.f = nil
+
+	// closing the lock file releases the directory
+	if cerr := w.lock.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// record of an operation: the length of op and payload, their checksum, op and the payload
+func record(op byte, payload []byte) []byte {
+	var b = make([]byte, recordHeader+1+len(payload))
+
+	b[recordHeader] = op
+	copy(b[recordHeader+1:], payload)
+
+	binary.LittleEndian.PutUint32(b, uint32(1+len(payload)))
+	binary.LittleEndian.PutUint32(b[4:], crc32.Checksum(b[recordHeader:], crcTable))
+
+	return b
+}
+
+// readRecord returns the operation and payload of the next record and the number of bytes it takes
+func readRecord(r io.Reader) (byte, []byte, int64, error) {
+	var header = make([]byte, recordHeader)
+
+	if _, err := io.ReadFull(r, header); err == io.EOF {
+		return 0, nil, 0, io.EOF
+	} else if err != nil {
+		return 0, nil, 0, errTorn
+	}
+
+	var (
+		size = binary.LittleEndian.Uint32(header)
+		sum  = binary.LittleEndian.Uint32(header[4:])
+	)
+
+	if size == 0 || size > 1<<24 {
+		return 0, nil, 0, errTorn
+	}
+
+	var b = make([]byte, size)
+
+	if _, err := io.ReadFull(r, b); err != nil || crc32.Checksum(b, crcTable) != sum {
+		return 0, nil, 0, errTorn
+	}
+
+	return b[0], b[1:], int64(recordHeader + size), nil
+}
+
+// generations of the snapshots and logs in dir, in ascending order
+func generations(dir string) (snapshots, logs []uint64, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		var gen uint64
+
+		if _, err := fmt.Sscanf(e.Name(), snapshotPrefix+"%016x"+fileExt, &gen); err == nil && e.Name() == name(snapshotPrefix, gen) {
+			snapshots = append(snapshots, gen)
+		} else if _, err := fmt.Sscanf(e.Name(), walPrefix+"%016x"+fileExt, &gen); err == nil && e.Name() == name(walPrefix, gen) {
+			logs = append(logs, gen)
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i] < snapshots[j] })
+	sort.Slice(logs, func(i, j int) bool { return logs[i] < logs[j] })
+
+	return snapshots, logs, nil
+}
+
+// name of the snapshot or log file of a generation, the generation is zero padded so that files sort by it
+func name(prefix string, gen uint64) string {
+	return fmt.Sprintf("%s%016x%s", prefix, gen, fileExt)
+}
+
+// syncDir flushes the entries of a directory so that created and renamed files survive a crash
+func syncDir(dir string) error {
+	var d, err = os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer internal.Closed(d)
+
+	return d.Sync()
+}
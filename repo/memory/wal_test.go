@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo/repotest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func open(t *testing.T, dir string, opts ...Option) *Repo {
+	var r, err = Open(dir, opts...)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	t.Cleanup(func() {
+		r.Close()
+	})
+
+	return r
+}
+
+func draw(n int) undeck.UpdateFunc {
+	return func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(n)
+		return d, err
+	}
+}
+
+func TestOpen_Recover(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+		r   = open(t, dir, WithSync(SyncNever, 0))
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1", Hidden: true}.Add(french.All()...))
+	r.Save(ctx, undeck.Deck{ID: "2"}.Add(french.All()...))
+	r.Save(ctx, undeck.Deck{ID: "3"}.Add(french.All()...))
+	r.Update(ctx, "1", draw(5))
+	r.Delete(ctx, "2")
+
+	var want = r.Dump()
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var got = open(t, dir).Dump()
+
+	if len(got) != len(want) {
+		t.Fatalf("recovered %d decks, want %d", len(got), len(want))
+	}
+
+	for id, d := range want {
+		internal.AssertDecksEqual(t, d, got[id])
+		internal.AssertCardSlicesEqual(t, d.Drawn(), got[id].Drawn())
+	}
+}
+
+func TestOpen_Locked(t *testing.T) {
+	var dir = t.TempDir()
+
+	open(t, dir)
+
+	if _, err := Open(dir); !errors.Is(err, internal.ErrFileLocked) {
+		t.Errorf("Open() of a directory already open error = %v, want %v", err, internal.ErrFileLocked)
+	}
+}
+
+func TestOpen_Evict(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		dir   = t.TempDir()
+		now   = time.Now()
+		clock = WithClock(func() time.Time { return now })
+		r     = open(t, dir, WithTTL(time.Hour, 0), clock)
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+	r.Save(ctx, undeck.Deck{ID: "2"}.Add(french.All()...))
+
+	now = now.Add(30 * time.Minute)
+	r.Update(ctx, "2", draw(1))
+
+	now = now.Add(45 * time.Minute)
+
+	if err := r.Evict(); err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+
+	r.Close()
+
+	var got = open(t, dir, WithTTL(time.Hour, 0), clock).Dump()
+
+	if _, ok := got["1"]; ok || len(got) != 1 {
+		t.Errorf("recovered %d decks, want only deck 2 since deck 1 was evicted", len(got))
+	}
+}
+
+func TestOpen_Snapshot(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+		r   = open(t, dir)
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+	r.Save(ctx, undeck.Deck{ID: "2"}.Add(french.All()...))
+
+	if err := r.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	r.Update(ctx, "1", draw(1))
+	r.Delete(ctx, "2")
+
+	if err := r.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	r.Save(ctx, undeck.Deck{ID: "3"}.Add(french.All()...))
+	r.Close()
+
+	snapshots, logs, _ := generations(dir)
+	if len(snapshots) != 1 || len(logs) != 1 || logs[0] != snapshots[0]+1 {
+		t.Errorf("files after snapshots = %v snapshots, %v logs, want the last snapshot and the log after it", snapshots, logs)
+	}
+
+	var got = open(t, dir)
+
+	if d, err := got.Find(ctx, "1"); err != nil || d.Version != 2 || d.Remaining() != 51 {
+		t.Errorf("Find() = version %d with %d cards (%v), want version 2 with 51", d.Version, d.Remaining(), err)
+	}
+
+	if _, err := got.Find(ctx, "2"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() deleted deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if _, err := got.Find(ctx, "3"); err != nil {
+		t.Errorf("Find() deck saved after the snapshot error = %v", err)
+	}
+}
+
+func TestOpen_TornRecord(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+		r   = open(t, dir)
+	)
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+	r.Close()
+
+	var (
+		_, logs, _ = generations(dir)
+		path       = filepath.Join(dir, name(walPrefix, logs[len(logs)-1]))
+		info, _    = os.Stat(path)
+		f, _       = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	)
+
+	// the process died halfway through writing the second record
+	f.Write(record(opPut, []byte("a deck which never made it"))[:12])
+	f.Close()
+
+	var got = open(t, dir)
+
+	if _, err := got.Find(ctx, "1"); err != nil {
+		t.Errorf("Find() error = %v", err)
+	}
+
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Errorf("log size after recovery = %d, want the torn record cut to %d", after.Size(), info.Size())
+	}
+}
+
+// TestOpen_Killed has a child process save decks until it is killed, every deck it reported as saved must be recovered
+func TestOpen_Killed(t *testing.T) {
+	if dir := os.Getenv("UNDECK_WAL_DIR"); dir != "" {
+		saveForever(dir)
+		return
+	}
+
+	for _, policy := range []string{"always", "interval", "never"} {
+		t.Run(policy, func(t *testing.T) {
+			var (
+				dir = t.TempDir()
+				cmd = exec.Command(os.Args[0], "-test.run=^TestOpen_Killed$")
+			)
+
+			cmd.Env = append(os.Environ(), "UNDECK_WAL_DIR="+dir, "UNDECK_WAL_SYNC="+policy)
+
+			out, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				saved int
+				s     = bufio.NewScanner(out)
+			)
+
+			for saved < 200 && s.Scan() {
+				if n, err := strconv.Atoi(strings.TrimPrefix(s.Text(), "saved ")); err == nil {
+					saved = n
+				}
+			}
+
+			cmd.Process.Kill()
+			cmd.Wait()
+
+			if saved < 200 {
+				t.Fatalf("child process saved %d decks before it stopped, want 200", saved)
+			}
+
+			var r = open(t, dir)
+
+			for i := 1; i <= saved; i++ {
+				var d, err = r.Find(context.Background(), strconv.Itoa(i))
+				if err != nil || d.Version != 2 || d.Remaining() != 51 {
+					t.Fatalf("deck %d = version %d with %d cards (%v), want version 2 with 51", i, d.Version, d.Remaining(), err)
+				}
+			}
+		})
+	}
+}
+
+// saveForever saves and updates decks in dir, taking snapshots along the way, reporting every deck once done
+func saveForever(dir string) {
+	var policy = map[string]SyncPolicy{"always": SyncAlways, "interval": SyncInterval, "never": SyncNever}[os.Getenv("UNDECK_WAL_SYNC")]
+
+	var r, err = Open(dir, WithSync(policy, time.Millisecond))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var ctx = context.Background()
+
+	for i := 1; ; i++ {
+		var id = strconv.Itoa(i)
+
+		if _, err = r.Save(ctx, undeck.Deck{ID: id}.Add(french.All()...)); err == nil {
+			_, err = r.Update(ctx, id, draw(1))
+		}
+
+		if err == nil && i%50 == 0 {
+			err = r.Snapshot()
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println("saved", i)
+	}
+}
+
+func TestOpen_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return open(t, t.TempDir())
+	})
+}
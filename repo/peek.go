@@ -0,0 +1,17 @@
+package repo
+
+import "context"
+
+type peekKey struct{}
+
+// WithPeek returns a context whose finds leave decks as they are, without refreshing their access time nor their
+// expiry, e.g. to check whether decks still exist in the background
+func WithPeek(ctx context.Context) context.Context {
+	return context.WithValue(ctx, peekKey{}, true)
+}
+
+// Peek reports whether finds with ctx must leave decks as they are
+func Peek(ctx context.Context) bool {
+	var p, _ = ctx.Value(peekKey{}).(bool)
+	return p
+}
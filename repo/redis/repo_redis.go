@@ -0,0 +1,377 @@
+// Package redis stores decks in redis so that several servers can share them, decks expire through redis key expiry
+package redis
+
+import (
+	"context"
+	"errors"
+	goredis "github.com/redis/go-redis/v9"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"sync"
+	"time"
+)
+
+// maxRetries of an update when the deck is changed concurrently by another client
+const maxRetries = 100
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithTTL expires decks not accessed for ttl, expired decks are reported as such for grace before being forgotten
+func WithTTL(ttl, grace time.Duration) Option {
+	return func(r *Repo) {
+		r.ttl = ttl
+		r.grace = grace
+	}
+}
+
+// WithPrefix namespaces the keys of the repo, "undeck:" by default
+func WithPrefix(prefix string) Option {
+	return func(r *Repo) {
+		r.prefix = prefix
+	}
+}
+
+// WithIDGenerator replaces the uuid generator used for new decks
+func WithIDGenerator(fi repo.IDGenerator) Option {
+	return func(r *Repo) {
+		r.idGenerator = fi
+	}
+}
+
+// WithClock replaces the clock used for timestamps, expiry is always left to redis
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// New repo using the redis client
+func New(client goredis.UniversalClient, opts ...Option) *Repo {
+	var r = &Repo{
+		client: client,
+		prefix: "undeck:",
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Repo stores each deck binary encoded under its own key, writes are optimistic transactions watching that key.
+// An index of ids is kept in a sorted set for listing, ids of expired decks are pruned from it as they are found
+type Repo struct {
+	mu          sync.Mutex
+	client      goredis.UniversalClient
+	prefix      string
+	idGenerator repo.IDGenerator
+	now         repo.Clock
+	ttl         time.Duration
+	grace       time.Duration
+}
+
+func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
+	if err := ctx.Err(); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		d       undeck.Deck
+		id, err = repo.NewID(r.idGenerator, func(id string) (bool, error) {
+			var n, err = r.client.Exists(ctx, r.key(id), r.goneKey(id)).Result()
+			return n > 0, err
+		})
+	)
+
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = id
+	d.CreatedAt = r.now()
+
+	return d, nil
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var err = r.client.Watch(ctx, func(tx *goredis.Tx) error {
+		if d, err := r.get(ctx, tx, deck.ID); err == nil && d.Version != deck.Version {
+			return undeck.ErrVersionConflict
+		} else if err != nil && err != undeck.ErrDeckNotFound && err != undeck.ErrDeckExpired {
+			return err
+		}
+
+		deck.Version++
+		deck.AccessedAt = r.now()
+
+		return r.put(ctx, tx, deck)
+	}, r.key(deck.ID))
+
+	if err == goredis.TxFailedErr {
+		return deck, undeck.ErrVersionConflict
+	}
+
+	return deck, err
+}
+
+// Find a deck by id, refreshing its expiry unless ctx is from repo.WithPeek
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	var d, err = r.get(ctx, r.client, id)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	if r.ttl > 0 && !repo.Peek(ctx) {
+		r.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+			p.PExpire(ctx, r.key(id), r.ttl)
+			p.PExpire(ctx, r.goneKey(id), r.ttl+r.grace)
+			return nil
+		})
+	}
+
+	return d, nil
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var d undeck.Deck
+
+	for i := 0; i < maxRetries; i++ {
+		var err = r.client.Watch(ctx, func(tx *goredis.Tx) error {
+			var current, err = r.get(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+
+			if d, err = fn(current); err != nil {
+				return err
+			}
+
+			d.Version = current.Version + 1
+			d.AccessedAt = r.now()
+
+			return r.put(ctx, tx, d)
+		}, r.key(id))
+
+		if err == goredis.TxFailedErr {
+			continue
+		}
+
+		if err != nil {
+			return undeck.Deck{}, err
+		}
+
+		return d, nil
+	}
+
+	return undeck.Deck{}, undeck.ErrVersionConflict
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	if check := undeck.DeleteCheck(ctx); check != nil {
+		return r.deleteChecked(ctx, id, check)
+	}
+
+	var n, err = r.client.Del(ctx, r.key(id)).Result()
+	if err != nil {
+		return err
+	}
+
+	r.client.ZRem(ctx, r.indexKey(), id)
+	r.client.Publish(ctx, r.changesKey(), id)
+
+	if n == 0 {
+		return r.missing(ctx, r.client, id)
+	}
+
+	return r.client.Del(ctx, r.goneKey(id)).Err()
+}
+
+// deleteChecked deletes a deck accepted by check in an optimistic transaction watching its key
+func (r *Repo) deleteChecked(ctx context.Context, id string, check undeck.DeleteFunc) error {
+	var err = r.client.Watch(ctx, func(tx *goredis.Tx) error {
+		var d, err = r.get(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = check(d); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+			p.Del(ctx, r.key(id), r.goneKey(id))
+			p.ZRem(ctx, r.indexKey(), id)
+			p.Publish(ctx, r.changesKey(), id)
+			return nil
+		})
+
+		return err
+	}, r.key(id))
+
+	if err == goredis.TxFailedErr {
+		return undeck.ErrVersionConflict
+	}
+
+	return err
+}
+
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	var (
+		page   undeck.ListPage
+		size   = filter.PageSize()
+		cursor = filter.Cursor
+	)
+
+	for {
+		var min = "-"
+		if cursor != "" {
+			min = "(" + cursor
+		}
+
+		var ids, err = r.client.ZRangeByLex(ctx, r.indexKey(), &goredis.ZRangeBy{
+			Min:   min,
+			Max:   "+",
+			Count: int64(size + 1),
+		}).Result()
+
+		if err != nil || len(ids) == 0 {
+			return page, err
+		}
+
+		var keys = make([]string, len(ids))
+		for i := range ids {
+			keys[i] = r.key(ids[i])
+		}
+
+		values, err := r.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return page, err
+		}
+
+		for i, v := range values {
+			var s, ok = v.(string)
+			if !ok {
+				r.client.ZRem(ctx, r.indexKey(), ids[i])
+				continue
+			}
+
+			var d, err = undeck.UnmarshalDeck([]byte(s))
+			if err != nil {
+				return page, err
+			}
+
+			if !filter.Match(d) {
+				continue
+			}
+
+			if len(page.Decks) == size {
+				page.Next = page.Decks[size-1].ID
+				return page, nil
+			}
+
+			page.Decks = append(page.Decks, d)
+		}
+
+		cursor = ids[len(ids)-1]
+	}
+}
+
+func (r *Repo) key(id string) string {
+	return r.prefix + "deck:" + id
+}
+
+// goneKey outlives the deck by the grace period to tell expired decks from unknown ones
+func (r *Repo) goneKey(id string) string {
+	return r.prefix + "gone:" + id
+}
+
+func (r *Repo) indexKey() string {
+	return r.prefix + "decks"
+}
+
+// changesKey is the channel the ids of changed decks are published to
+func (r *Repo) changesKey() string {
+	return r.prefix + "changes"
+}
+
+// Changes calls fn with the id of every deck saved or deleted by any client of the redis server, including this one,
+// until ctx is done. It is meant to keep caches of other instances fresh
+func (r *Repo) Changes(ctx context.Context, fn func(id string)) error {
+	var sub = r.client.Subscribe(ctx, r.changesKey())
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+
+	var ch = sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			fn(m.Payload)
+		}
+	}
+}
+
+func (r *Repo) get(ctx context.Context, c goredis.Cmdable, id string) (undeck.Deck, error) {
+	var s, err = c.Get(ctx, r.key(id)).Result()
+
+	if errors.Is(err, goredis.Nil) {
+		return undeck.Deck{}, r.missing(ctx, c, id)
+	}
+
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return undeck.UnmarshalDeck([]byte(s))
+}
+
+// missing tells whether a deck which cannot be found has expired recently or is unknown
+func (r *Repo) missing(ctx context.Context, c goredis.Cmdable, id string) error {
+	var n, err = c.Exists(ctx, r.goneKey(id)).Result()
+
+	switch {
+	case err != nil:
+		return err
+	case n > 0:
+		return undeck.ErrDeckExpired
+	}
+
+	return undeck.ErrDeckNotFound
+}
+
+// put the deck in a transaction of tx, refreshing its expiry
+func (r *Repo) put(ctx context.Context, tx *goredis.Tx, d undeck.Deck) error {
+	var v, err = d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		p.Set(ctx, r.key(d.ID), v, r.ttl)
+		p.ZAdd(ctx, r.indexKey(), goredis.Z{Member: d.ID})
+		p.Publish(ctx, r.changesKey(), d.ID)
+
+		if r.ttl > 0 {
+			p.Set(ctx, r.goneKey(d.ID), "", r.ttl+r.grace)
+		}
+
+		return nil
+	})
+
+	return err
+}
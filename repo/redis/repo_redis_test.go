@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/repotest"
+	"testing"
+	"time"
+)
+
+func newRepo(t *testing.T, opts ...Option) (*Repo, *miniredis.Miniredis) {
+	var (
+		s      = miniredis.RunT(t)
+		client = goredis.NewClient(&goredis.Options{Addr: s.Addr()})
+	)
+
+	t.Cleanup(func() {
+		client.Close()
+	})
+
+	return New(client, opts...), s
+}
+
+func TestRepo_Expiry(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		r, s = newRepo(t, WithTTL(time.Hour, time.Minute))
+	)
+
+	for _, id := range []string{"1", "2"} {
+		if _, err := r.Save(ctx, undeck.Deck{ID: id}.Add(french.All()...)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	s.FastForward(59 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil {
+		t.Fatalf("Find() before ttl error = %v", err)
+	}
+
+	if _, err := r.Find(repo.WithPeek(ctx), "2"); err != nil {
+		t.Fatalf("Find() peeking before ttl error = %v", err)
+	}
+
+	s.FastForward(59 * time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != nil {
+		t.Fatalf("Find() refreshed before ttl error = %v", err)
+	}
+
+	// expired past its grace already
+	if _, err := r.Find(ctx, "2"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() peeked at deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	s.FastForward(time.Hour)
+
+	if _, err := r.Find(ctx, "1"); err != undeck.ErrDeckExpired {
+		t.Errorf("Find() after ttl error = %v, want %v", err, undeck.ErrDeckExpired)
+	}
+
+	if page, err := r.List(ctx, undeck.ListFilter{}); err != nil || len(page.Decks) != 0 {
+		t.Errorf("List() after ttl = %d decks, want 0 (%v)", len(page.Decks), err)
+	}
+
+	s.FastForward(time.Minute)
+
+	if _, err := r.Find(ctx, "1"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() after grace error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func TestRepo_Changes(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		r, s        = newRepo(t)
+		other       = New(goredis.NewClient(&goredis.Options{Addr: s.Addr()}))
+		changed     = make(chan string, 2)
+		done        = make(chan error)
+	)
+
+	defer cancel()
+
+	go func() {
+		done <- r.Changes(ctx, func(id string) { changed <- id })
+	}()
+
+	for len(s.PubSubChannels("")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := other.Save(ctx, undeck.Deck{ID: "1"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := other.Delete(ctx, "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	for _, want := range []string{"1", "1"} {
+		select {
+		case got := <-changed:
+			if got != want {
+				t.Errorf("Changes() id = %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Changes() timed out waiting for %q", want)
+		}
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("Changes() error = %v", err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		var r, _ = newRepo(t)
+		return r
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		var r, _ = newRepo(t, WithIDGenerator(gen))
+		return r
+	})
+}
@@ -1,19 +1,33 @@
 package repo
 
 import (
-	"strconv"
+	"go.fluxy.net/undeck"
+	"time"
 )
 
-// IDGenerator is a function that can be used by repo to generate ids
-type IDGenerator func() string
+// Clock returns the current time, it can be replaced in repos for testing
+type Clock func() time.Time
 
-// Sequential returns ids from a sequence starting from 1
-func Sequential(ids ...string) IDGenerator {
-	var i = 1
+// Decorator is a repo adding behaviour to the repo it wraps, e.g. keeping the history of decks
+type Decorator interface {
+	undeck.Repo
+	Unwrap() undeck.Repo
+}
+
+// Layers of a repo, the repo itself first followed by the repos it decorates
+func Layers(r undeck.Repo) []undeck.Repo {
+	var layers []undeck.Repo
+
+	for r != nil {
+		layers = append(layers, r)
 
-	return func() string {
-		var id = strconv.Itoa(i)
-		i++
-		return id
+		var d, ok = r.(Decorator)
+		if !ok {
+			break
+		}
+
+		r = d.Unwrap()
 	}
+
+	return layers
 }
@@ -0,0 +1,543 @@
+// Package repotest checks that an undeck.Repo implementation behaves the way the rest of undeck expects
+package repotest
+
+import (
+	"context"
+	"errors"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory makes a new empty repo, it is called once per test
+type Factory func(t *testing.T) undeck.Repo
+
+// GeneratorFactory makes a new empty repo generating ids with gen
+type GeneratorFactory func(t *testing.T, gen repo.IDGenerator) undeck.Repo
+
+// Run the conformance suite against repos made by factory
+func Run(t *testing.T, factory Factory) {
+	var tests = []struct {
+		name string
+		test func(t *testing.T, r undeck.Repo)
+	}{
+		{name: "Create", test: testCreate},
+		{name: "NotFound", test: testNotFound},
+		{name: "RoundTrip", test: testRoundTrip},
+		{name: "VersionConflict", test: testVersionConflict},
+		{name: "Update", test: testUpdate},
+		{name: "ConcurrentSave", test: testConcurrentSave},
+		{name: "ConcurrentUpdate", test: testConcurrentUpdate},
+		{name: "UniqueIDs", test: testUniqueIDs},
+		{name: "Delete", test: testDelete},
+		{name: "DeleteCheck", test: testDeleteCheck},
+		{name: "List", test: testList},
+		{name: "Canceled", test: testCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+// RunCollisions checks that repos made by factory skip generated ids already taken by stored decks
+func RunCollisions(t *testing.T, factory GeneratorFactory) {
+	var ctx = context.Background()
+
+	t.Run("Retry", func(t *testing.T) {
+		var r = factory(t, repo.Sequential("a", "a", "b"))
+
+		if _, err := r.Save(ctx, undeck.Deck{ID: "a"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		d, err := r.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		if d.ID != "b" {
+			t.Errorf("Create() id = %q, want %q", d.ID, "b")
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		var r = factory(t, func() string { return "a" })
+
+		if _, err := r.Save(ctx, undeck.Deck{ID: "a"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		if _, err := r.Create(ctx); err != undeck.ErrIDCollision {
+			t.Errorf("Create() error = %v, want %v", err, undeck.ErrIDCollision)
+		}
+	})
+}
+
+func draw(n int) undeck.UpdateFunc {
+	return func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(n)
+		return d, err
+	}
+}
+
+// save a new deck with the full french set, drawing n cards first
+func save(t *testing.T, r undeck.Repo, n int) undeck.Deck {
+	var d, err = r.Create(context.Background())
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if d, _, err = d.Add(french.All()...).Draw(n); err != nil {
+		t.Fatalf("Draw() error = %v", err)
+	}
+
+	if d, err = r.Save(context.Background(), d); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	return d
+}
+
+func testCreate(t *testing.T, r undeck.Repo) {
+	var ctx = context.Background()
+
+	d, err := r.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if d.ID == "" {
+		t.Errorf("Create() id is empty")
+	}
+
+	if d.Version != 0 {
+		t.Errorf("Create() version = %d, want 0", d.Version)
+	}
+
+	if d.Remaining() != 0 || len(d.Drawn()) != 0 {
+		t.Errorf("Create() deck has %d cards and %d drawn, want none", d.Remaining(), len(d.Drawn()))
+	}
+
+	// a created deck is not stored until it is saved
+	if _, err = r.Find(ctx, d.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() created deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func testNotFound(t *testing.T, r undeck.Repo) {
+	var ctx = context.Background()
+
+	if _, err := r.Find(ctx, "missing"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if _, err := r.Update(ctx, "missing", draw(1)); err != undeck.ErrDeckNotFound {
+		t.Errorf("Update() error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if err := r.Delete(ctx, "missing"); err != undeck.ErrDeckNotFound {
+		t.Errorf("Delete() error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func testRoundTrip(t *testing.T, r undeck.Repo) {
+	var ctx = context.Background()
+
+	d, err := r.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	d = d.Add(cards.MustString(french.FromString, "AH,KS,2D,TC,9H")...)
+	d.IsShuffled = true
+	d.Hidden = true
+	d.ParentID = "parent"
+	d, _, _ = d.Draw(2)
+
+	saved, err := r.Save(ctx, d)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if saved.Version != 1 {
+		t.Errorf("Save() version = %d, want 1", saved.Version)
+	}
+
+	got, err := r.Find(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	internal.AssertDecksEqual(t, saved, got)
+	internal.AssertCardSlicesEqual(t, d.Drawn(), got.Drawn())
+
+	// an empty system stands for the default one, which repos may or may not store
+	if got.System != french.System && got.System != "" {
+		t.Errorf("Find() system = %q, want %q", got.System, french.System)
+	}
+
+	if !got.CreatedAt.Equal(d.CreatedAt) {
+		t.Errorf("Find() created at = %v, want %v", got.CreatedAt, d.CreatedAt)
+	}
+
+	// saving the found deck again moves it to the next version
+	if got, err = r.Save(ctx, got); err != nil || got.Version != 2 {
+		t.Errorf("Save() found deck = version %d (%v), want version 2", got.Version, err)
+	}
+}
+
+func testVersionConflict(t *testing.T, r undeck.Repo) {
+	var (
+		ctx = context.Background()
+		d   = save(t, r, 0)
+	)
+
+	stale, _, _ := d.Draw(1)
+	stale.Version = 0
+
+	if _, err := r.Save(ctx, stale); err != undeck.ErrVersionConflict {
+		t.Errorf("Save() stale version error = %v, want %v", err, undeck.ErrVersionConflict)
+	}
+
+	got, _ := r.Find(ctx, d.ID)
+	internal.AssertDecksEqual(t, d, got)
+}
+
+func testUpdate(t *testing.T, r undeck.Repo) {
+	var (
+		ctx     = context.Background()
+		d       = save(t, r, 0)
+		errStop = errors.New("stop")
+	)
+
+	updated, err := r.Update(ctx, d.ID, draw(3))
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if updated.Version != d.Version+1 || updated.Remaining() != 49 {
+		t.Errorf("Update() = version %d with %d cards, want version %d with 49", updated.Version, updated.Remaining(), d.Version+1)
+	}
+
+	got, _ := r.Find(ctx, d.ID)
+	internal.AssertDecksEqual(t, updated, got)
+
+	// a failing update leaves the deck as it was
+	_, err = r.Update(ctx, d.ID, func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, _ = d.Draw(1)
+		return d, errStop
+	})
+
+	if err != errStop {
+		t.Errorf("Update() error = %v, want %v", err, errStop)
+	}
+
+	got, _ = r.Find(ctx, d.ID)
+	internal.AssertDecksEqual(t, updated, got)
+}
+
+func testConcurrentSave(t *testing.T, r undeck.Repo) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		saved int
+
+		ctx = context.Background()
+		d   = save(t, r, 0)
+	)
+
+	// every save starts from the same version so only one of them may win
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(d undeck.Deck) {
+			defer wg.Done()
+
+			var _, err = r.Save(ctx, d)
+
+			switch err {
+			case nil:
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			case undeck.ErrVersionConflict:
+			default:
+				t.Errorf("Save() error = %v", err)
+			}
+		}(d.Duplicate())
+	}
+
+	wg.Wait()
+
+	if saved != 1 {
+		t.Errorf("concurrent saves of one version: %d succeeded, want 1", saved)
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, r undeck.Repo) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[string]int)
+
+		ctx = context.Background()
+		d   = save(t, r, 0)
+	)
+
+	for i := 0; i < 52; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var drawn []undeck.Card
+
+			_, err := r.Update(ctx, d.ID, func(d undeck.Deck) (undeck.Deck, error) {
+				var err error
+				d, drawn, err = d.Draw(1)
+				return d, err
+			})
+
+			if err != nil {
+				t.Errorf("Update() error = %v", err)
+				return
+			}
+
+			mu.Lock()
+			seen[drawn[0].String()]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(seen) != 52 {
+		t.Errorf("distinct cards drawn: want = 52, got = %d", len(seen))
+	}
+
+	if got, _ := r.Find(ctx, d.ID); got.Version != d.Version+52 {
+		t.Errorf("version after concurrent updates = %d, want %d", got.Version, d.Version+52)
+	}
+}
+
+func testUniqueIDs(t *testing.T, r undeck.Repo) {
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[string]bool)
+
+		ctx = context.Background()
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var d, err = r.Create(ctx)
+			if err != nil {
+				t.Errorf("Create() error = %v", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if ids[d.ID] {
+				t.Errorf("Create() id %q made twice", d.ID)
+			}
+
+			ids[d.ID] = true
+		}()
+	}
+
+	wg.Wait()
+}
+
+func testDelete(t *testing.T, r undeck.Repo) {
+	var (
+		ctx   = context.Background()
+		d     = save(t, r, 0)
+		other = save(t, r, 0)
+	)
+
+	if err := r.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := r.Find(ctx, d.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() deleted deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if err := r.Delete(ctx, d.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Delete() twice error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if _, err := r.Find(ctx, other.ID); err != nil {
+		t.Errorf("Find() other deck error = %v", err)
+	}
+}
+
+func testDeleteCheck(t *testing.T, r undeck.Repo) {
+	var (
+		d    = save(t, r, 0)
+		want = d.Version
+
+		check = func(d undeck.Deck) error {
+			if d.Version != want {
+				return undeck.ErrVersionConflict
+			}
+
+			return nil
+		}
+
+		ctx = undeck.WithDeleteCheck(context.Background(), check)
+	)
+
+	want = d.Version + 1
+
+	if err := r.Delete(ctx, d.ID); err != undeck.ErrVersionConflict {
+		t.Fatalf("Delete() rejected error = %v, want %v", err, undeck.ErrVersionConflict)
+	}
+
+	if _, err := r.Find(context.Background(), d.ID); err != nil {
+		t.Fatalf("Find() rejected deck error = %v", err)
+	}
+
+	want = d.Version
+
+	if err := r.Delete(ctx, d.ID); err != nil {
+		t.Fatalf("Delete() accepted error = %v", err)
+	}
+
+	if _, err := r.Find(context.Background(), d.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Find() deleted deck error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+
+	if err := r.Delete(ctx, d.ID); err != undeck.ErrDeckNotFound {
+		t.Errorf("Delete() twice error = %v, want %v", err, undeck.ErrDeckNotFound)
+	}
+}
+
+func testList(t *testing.T, r undeck.Repo) {
+	var (
+		ctx   = context.Background()
+		empty = 0
+		want  = make(map[string]bool)
+	)
+
+	for i := 0; i < 5; i++ {
+		want[save(t, r, 0).ID] = true
+	}
+
+	var drawn = save(t, r, 52)
+
+	var got = make(map[string]bool)
+
+	for filter := (undeck.ListFilter{Limit: 2}); ; {
+		var page, err = r.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		if len(page.Decks) > 2 {
+			t.Errorf("List() page of %d decks, want at most 2", len(page.Decks))
+		}
+
+		for i, d := range page.Decks {
+			if i > 0 && d.ID <= page.Decks[i-1].ID {
+				t.Errorf("List() %q listed after %q, want ordered by id", d.ID, page.Decks[i-1].ID)
+			}
+
+			if got[d.ID] {
+				t.Errorf("List() %q listed twice", d.ID)
+			}
+
+			got[d.ID] = true
+		}
+
+		if page.Next == "" {
+			break
+		}
+
+		filter.Cursor = page.Next
+	}
+
+	if len(got) != 6 {
+		t.Errorf("List() listed %d decks, want 6", len(got))
+	}
+
+	page, err := r.List(ctx, undeck.ListFilter{MaxRemaining: &empty})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if len(page.Decks) != 1 || page.Decks[0].ID != drawn.ID {
+		t.Errorf("List() empty decks = %d decks, want only %q", len(page.Decks), drawn.ID)
+	}
+
+	if page, err = r.List(ctx, undeck.ListFilter{CreatedBefore: drawn.CreatedAt.Add(-time.Hour)}); err != nil || len(page.Decks) != 0 {
+		t.Errorf("List() created before = %d decks (%v), want 0", len(page.Decks), err)
+	}
+}
+
+// testCanceled makes sure every operation given a done context fails with the context error and has no effect
+func testCanceled(t *testing.T, r undeck.Repo) {
+	var (
+		canceled, cancel = context.WithCancel(context.Background())
+		expired, expire  = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	)
+
+	cancel()
+	defer expire()
+
+	for _, ctx := range []context.Context{canceled, expired} {
+		var (
+			want = ctx.Err()
+			d    = save(t, r, 0)
+			next = d.Duplicate()
+		)
+
+		next, _, _ = next.Draw(1)
+
+		if _, err := r.Create(ctx); !errors.Is(err, want) {
+			t.Errorf("Create() error = %v, want %v", err, want)
+		}
+
+		if _, err := r.Find(ctx, d.ID); !errors.Is(err, want) {
+			t.Errorf("Find() error = %v, want %v", err, want)
+		}
+
+		if _, err := r.Save(ctx, next); !errors.Is(err, want) {
+			t.Errorf("Save() error = %v, want %v", err, want)
+		}
+
+		if _, err := r.Update(ctx, d.ID, draw(1)); !errors.Is(err, want) {
+			t.Errorf("Update() error = %v, want %v", err, want)
+		}
+
+		if err := r.Delete(ctx, d.ID); !errors.Is(err, want) {
+			t.Errorf("Delete() error = %v, want %v", err, want)
+		}
+
+		if _, err := r.List(ctx, undeck.ListFilter{}); !errors.Is(err, want) {
+			t.Errorf("List() error = %v, want %v", err, want)
+		}
+
+		got, err := r.Find(context.Background(), d.ID)
+		if err != nil {
+			t.Fatalf("Find() after %v error = %v", want, err)
+		}
+
+		internal.AssertDecksEqual(t, d, got)
+	}
+}
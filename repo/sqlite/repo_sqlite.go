@@ -0,0 +1,370 @@
+// Package sqlite stores decks in a SQLite database using a pure Go driver
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations of the schema, applied in order and tracked with PRAGMA user_version. Never edit one, append instead
+var migrations = []string{
+	`CREATE TABLE decks (
+		id          TEXT PRIMARY KEY,
+		parent_id   TEXT NOT NULL,
+		system      TEXT NOT NULL,
+		shuffled    INTEGER NOT NULL,
+		hidden      INTEGER NOT NULL,
+		version     INTEGER NOT NULL,
+		created_at  INTEGER NOT NULL,
+		accessed_at INTEGER NOT NULL,
+		remaining   INTEGER NOT NULL,
+		deck        BLOB NOT NULL
+	);
+	CREATE INDEX decks_created_at ON decks (created_at);
+	CREATE INDEX decks_remaining ON decks (remaining);`,
+}
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithIDGenerator replaces the uuid generator used for new decks
+func WithIDGenerator(fi repo.IDGenerator) Option {
+	return func(r *Repo) {
+		r.idGenerator = fi
+	}
+}
+
+// WithClock replaces the clock used for timestamps
+func WithClock(c repo.Clock) Option {
+	return func(r *Repo) {
+		r.now = c
+	}
+}
+
+// New repo using the database file at path, created and migrated as needed
+func New(path string, opts ...Option) (*Repo, error) {
+	var dsn = "file:" + path + "?" + url.Values{
+		"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "foreign_keys(1)"},
+		"_txlock": {"immediate"},
+	}.Encode()
+
+	var db, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var r = &Repo{
+		db:  db,
+		now: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err = r.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Repo stores decks in a single table, one row per deck binary encoded, see undeck.Deck.MarshalBinary. The id,
+// version, timestamps and remaining cards have their own columns to be looked up and filtered on
+type Repo struct {
+	mu          sync.Mutex
+	db          *sql.DB
+	idGenerator repo.IDGenerator
+	now         repo.Clock
+}
+
+// Close the database
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
+
+// migrate the schema to the latest version
+func (r *Repo) migrate(ctx context.Context) error {
+	var tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var version int
+	if err = tx.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		if _, err = tx.ExecContext(ctx, migrations[version]); err != nil {
+			return err
+		}
+	}
+
+	// PRAGMA does not accept bound parameters
+	if _, err = tx.ExecContext(ctx, `PRAGMA user_version = `+strconv.Itoa(version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
+	if err := ctx.Err(); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		d       undeck.Deck
+		id, err = repo.NewID(r.idGenerator, func(id string) (bool, error) {
+			var err = r.db.QueryRowContext(ctx, `SELECT 1 FROM decks WHERE id = ?`, id).Scan(new(int))
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+
+			return err == nil, err
+		})
+	)
+
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = id
+	d.CreatedAt = r.now()
+
+	return d, nil
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return deck, err
+	}
+
+	defer tx.Rollback()
+
+	var version int
+
+	if err = tx.QueryRowContext(ctx, `SELECT version FROM decks WHERE id = ?`, deck.ID).Scan(&version); err == nil {
+		if version != deck.Version {
+			return deck, undeck.ErrVersionConflict
+		}
+	} else if err != sql.ErrNoRows {
+		return deck, err
+	}
+
+	deck.Version++
+	deck.AccessedAt = r.now()
+
+	if err = r.upsert(ctx, tx, deck); err != nil {
+		return deck, err
+	}
+
+	return deck, tx.Commit()
+}
+
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	return r.scan(r.db.QueryRowContext(ctx, `SELECT deck FROM decks WHERE id = ?`, id))
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	defer tx.Rollback()
+
+	current, err := r.scan(tx.QueryRowContext(ctx, `SELECT deck FROM decks WHERE id = ?`, id))
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	d, err := fn(current)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	d.Version = current.Version + 1
+	d.AccessedAt = r.now()
+
+	if err = r.upsert(ctx, tx, d); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	var tx, err = r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if check := undeck.DeleteCheck(ctx); check != nil {
+		var d, err = r.scan(tx.QueryRowContext(ctx, `SELECT deck FROM decks WHERE id = ?`, id))
+		if err != nil {
+			return err
+		}
+
+		if err = check(d); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM decks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return undeck.ErrDeckNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	var (
+		page  undeck.ListPage
+		size  = filter.PageSize()
+		where = []string{`id > ?`}
+		args  = []interface{}{filter.Cursor}
+	)
+
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, `created_at < ?`)
+		args = append(args, filter.CreatedBefore.UnixNano())
+	}
+
+	if filter.MinRemaining != nil {
+		where = append(where, `remaining >= ?`)
+		args = append(args, *filter.MinRemaining)
+	}
+
+	if filter.MaxRemaining != nil {
+		where = append(where, `remaining <= ?`)
+		args = append(args, *filter.MaxRemaining)
+	}
+
+	args = append(args, size+1)
+
+	var rows, err = r.db.QueryContext(
+		ctx,
+		`SELECT deck FROM decks WHERE `+strings.Join(where, ` AND `)+` ORDER BY id LIMIT ?`,
+		args...,
+	)
+
+	if err != nil {
+		return page, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var d, err = r.scan(rows)
+		if err != nil {
+			return page, err
+		}
+
+		page.Decks = append(page.Decks, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+
+	if len(page.Decks) > size {
+		page.Decks = page.Decks[:size]
+		page.Next = page.Decks[size-1].ID
+	}
+
+	return page, nil
+}
+
+func (r *Repo) upsert(ctx context.Context, tx *sql.Tx, d undeck.Deck) error {
+	var b, err = d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var state = undeck.ToDeckState(d)
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO decks (id, parent_id, system, shuffled, hidden, version, created_at, accessed_at, remaining, deck)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			parent_id = excluded.parent_id,
+			system = excluded.system,
+			shuffled = excluded.shuffled,
+			hidden = excluded.hidden,
+			version = excluded.version,
+			created_at = excluded.created_at,
+			accessed_at = excluded.accessed_at,
+			remaining = excluded.remaining,
+			deck = excluded.deck`,
+		state.ID,
+		state.ParentID,
+		state.System,
+		state.Shuffled,
+		state.Hidden,
+		state.Version,
+		unixNano(state.CreatedAt),
+		unixNano(state.AccessedAt),
+		len(state.Cards),
+		b,
+	)
+
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan a row of the deck column into a deck
+func (r *Repo) scan(row scanner) (undeck.Deck, error) {
+	var encoded []byte
+
+	var err = row.Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return undeck.Deck{}, undeck.ErrDeckNotFound
+	} else if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return undeck.UnmarshalDeck(encoded)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/repotest"
+	"path/filepath"
+	"testing"
+)
+
+func newRepo(t *testing.T, opts ...Option) *Repo {
+	var r, err = New(filepath.Join(t.TempDir(), "undeck.db"), opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	t.Cleanup(func() {
+		r.Close()
+	})
+
+	return r
+}
+
+func TestRepo_Migrate(t *testing.T) {
+	var (
+		path   = filepath.Join(t.TempDir(), "undeck.db")
+		r, err = New(path)
+	)
+
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	r.Close()
+
+	if r, err = New(path); err != nil {
+		t.Fatalf("New() on migrated database error = %v", err)
+	}
+
+	defer r.Close()
+
+	var version int
+	if err = r.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil || version != len(migrations) {
+		t.Errorf("user_version = %d, want %d (%v)", version, len(migrations), err)
+	}
+}
+
+func TestRepo_Binary(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = newRepo(t)
+	)
+
+	d, _, err := undeck.Deck{ID: "1", System: french.System}.Add(cards.MustString(french.FromString, "AH,KS,2D")...).Draw(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err = r.Save(ctx, d); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	var (
+		encoded   []byte
+		remaining int
+	)
+
+	if err = r.db.QueryRow(`SELECT deck, remaining FROM decks WHERE id = '1'`).Scan(&encoded, &remaining); err != nil || len(encoded) == 0 || encoded[0] != undeck.BinaryVersion {
+		t.Fatalf("deck column = %v (%v), want the binary encoding", encoded, err)
+	}
+
+	if remaining != 2 {
+		t.Errorf("remaining column = %d, want 2", remaining)
+	}
+
+	got, err := r.Find(ctx, "1")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	internal.AssertDecksEqual(t, d, got)
+	internal.AssertCardSlicesEqual(t, d.Drawn(), got.Drawn())
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return newRepo(t)
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		return newRepo(t, WithIDGenerator(gen))
+	})
+}
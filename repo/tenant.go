@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"context"
+	"errors"
+)
+
+// MaxTenantLength is the longest tenant name accepted
+const MaxTenantLength = 64
+
+// ErrInvalidTenant in case a tenant name is empty, too long or has characters other than letters, digits, '.', '_'
+// and '-'
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+type tenantKey struct{}
+
+// WithTenant returns a context for the decks of tenant, repos scoped by tenant only see the decks of that tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant of a context, empty if none was set
+func Tenant(ctx context.Context) string {
+	var t, _ = ctx.Value(tenantKey{}).(string)
+	return t
+}
+
+// ValidTenant fails with ErrInvalidTenant if tenant cannot be used to scope decks
+func ValidTenant(tenant string) error {
+	if tenant == "" || len(tenant) > MaxTenantLength {
+		return ErrInvalidTenant
+	}
+
+	for _, c := range tenant {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return ErrInvalidTenant
+		}
+	}
+
+	return nil
+}
+
+// Key of a deck in the tenant of ctx, the id prefixed by the tenant and a colon, or the id itself without tenant.
+// Tenants cannot have a colon so that keys of different tenants never clash
+func Key(ctx context.Context, id string) string {
+	if t := Tenant(ctx); t != "" {
+		return t + ":" + id
+	}
+
+	return id
+}
@@ -0,0 +1,342 @@
+// Package tenant scopes the decks of a repo by the tenant of the context, so that tenants sharing a repo never see
+// each other's decks
+package tenant
+
+import (
+	"context"
+	"errors"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNoTenant in case the context has no tenant set with repo.WithTenant
+	ErrNoTenant = errors.New("no tenant")
+
+	// ErrQuotaExceeded in case a tenant already has as many decks as its quota allows
+	ErrQuotaExceeded = errors.New("deck quota exceeded")
+)
+
+// Option configures a repo instance
+type Option func(r *Repo)
+
+// WithQuota limits how many decks each tenant may keep, zero for no limit
+func WithQuota(n int) Option {
+	return func(r *Repo) {
+		r.quota = n
+	}
+}
+
+// WithTenantQuota limits how many decks tenant may keep instead of the quota set with WithQuota, zero for no limit
+func WithTenantQuota(tenant string, n int) Option {
+	return func(r *Repo) {
+		r.quotas[tenant] = n
+	}
+}
+
+// New decorates repo so that decks are stored under the tenant of the context, see repo.Key
+func New(repo undeck.Repo, opts ...Option) *Repo {
+	var r = &Repo{
+		Repo:   repo,
+		quotas: make(map[string]int),
+		usages: make(map[string]*usage),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Repo stores decks under the tenant of the context, which must be set with repo.WithTenant. Ids are prefixed with
+// the tenant on the way in and stripped on the way out, so the decorated repo holds the decks of every tenant while
+// each tenant only sees its own
+type Repo struct {
+	undeck.Repo
+
+	quota  int
+	quotas map[string]int
+
+	mu     sync.Mutex
+	usages map[string]*usage
+}
+
+// usage of the quota of a tenant, counted from the decorated repo on first use
+type usage struct {
+	mu      sync.Mutex
+	decks   int
+	counted bool
+}
+
+// Unwrap returns the decorated repo
+func (r *Repo) Unwrap() undeck.Repo {
+	return r.Repo
+}
+
+func (r *Repo) Create(ctx context.Context) (undeck.Deck, error) {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	// the decorated repo only makes sure the id is free without the prefix
+	for i := 0; i < repo.MaxIDAttempts; i++ {
+		var d, err = r.Repo.Create(ctx)
+		if err != nil {
+			return d, err
+		}
+
+		switch _, err = r.Repo.Find(ctx, prefix+d.ID); err {
+		case undeck.ErrDeckNotFound:
+			return d, nil
+		case nil, undeck.ErrDeckExpired:
+			continue
+		default:
+			return undeck.Deck{}, err
+		}
+	}
+
+	return undeck.Deck{}, undeck.ErrIDCollision
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return deck, err
+	}
+
+	var u *usage
+
+	// only new decks count towards the quota, the lock keeps concurrent saves from going over it
+	if deck.Version == 0 {
+		u = r.usage(ctx)
+	}
+
+	if u != nil {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		if err = r.reserve(ctx, prefix, u); err != nil {
+			return deck, err
+		}
+	}
+
+	var d undeck.Deck
+
+	if d, err = r.Repo.Save(ctx, in(prefix, deck)); err == nil && u != nil {
+		u.decks++
+	}
+
+	return out(prefix, d), err
+}
+
+func (r *Repo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	var d undeck.Deck
+
+	d, err = r.Repo.Find(ctx, prefix+id)
+
+	return out(prefix, d), err
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return undeck.Deck{}, err
+	}
+
+	var d undeck.Deck
+
+	d, err = r.Repo.Update(ctx, prefix+id, func(d undeck.Deck) (undeck.Deck, error) {
+		d, err := fn(out(prefix, d))
+		return in(prefix, d), err
+	})
+
+	return out(prefix, d), err
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return err
+	}
+
+	if check := undeck.DeleteCheck(ctx); check != nil {
+		ctx = undeck.WithDeleteCheck(ctx, func(d undeck.Deck) error {
+			return check(out(prefix, d))
+		})
+	}
+
+	var u = r.usage(ctx)
+	if u == nil {
+		return r.Repo.Delete(ctx, prefix+id)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err = r.Repo.Delete(ctx, prefix+id); err == nil && u.decks > 0 {
+		u.decks--
+	}
+
+	return err
+}
+
+// List the decks of the tenant. Decks are ordered by id, so those of a tenant follow each other in the decorated repo
+func (r *Repo) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	var prefix, err = r.prefix(ctx)
+	if err != nil {
+		return undeck.ListPage{}, err
+	}
+
+	filter.Cursor = prefix + filter.Cursor
+
+	var page undeck.ListPage
+
+	page, err = r.Repo.List(ctx, filter)
+	if err != nil {
+		return undeck.ListPage{}, err
+	}
+
+	var decks = page.Decks[:0]
+
+	for _, d := range page.Decks {
+		if !strings.HasPrefix(d.ID, prefix) {
+			page.Next = ""
+			break
+		}
+
+		decks = append(decks, out(prefix, d))
+	}
+
+	page.Decks = decks
+
+	if !strings.HasPrefix(page.Next, prefix) {
+		page.Next = ""
+	}
+
+	page.Next = strings.TrimPrefix(page.Next, prefix)
+
+	return page, nil
+}
+
+// prefix of the ids of the tenant of ctx in the decorated repo
+func (r *Repo) prefix(ctx context.Context) (string, error) {
+	var t = repo.Tenant(ctx)
+	if t == "" {
+		return "", ErrNoTenant
+	}
+
+	if err := repo.ValidTenant(t); err != nil {
+		return "", err
+	}
+
+	return repo.Key(ctx, ""), nil
+}
+
+// usage of the tenant of ctx, nil if it has no quota
+func (r *Repo) usage(ctx context.Context) *usage {
+	var t = repo.Tenant(ctx)
+
+	if r.quotaOf(t) <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var u, ok = r.usages[t]
+	if !ok {
+		u = &usage{}
+		r.usages[t] = u
+	}
+
+	return u
+}
+
+// quotaOf a tenant, zero for no limit
+func (r *Repo) quotaOf(tenant string) int {
+	if q, ok := r.quotas[tenant]; ok {
+		return q
+	}
+
+	return r.quota
+}
+
+// reserve room for a new deck, failing with ErrQuotaExceeded if the tenant has no room left. The decks are counted
+// again before refusing, as some may have expired since. The lock of u must be held
+func (r *Repo) reserve(ctx context.Context, prefix string, u *usage) error {
+	var quota = r.quotaOf(repo.Tenant(ctx))
+
+	if u.counted && u.decks < quota {
+		return nil
+	}
+
+	var n, err = r.count(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	u.decks, u.counted = n, true
+
+	if n >= quota {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// count the decks of a tenant in the decorated repo
+func (r *Repo) count(ctx context.Context, prefix string) (int, error) {
+	var (
+		n      int
+		filter = undeck.ListFilter{Cursor: prefix, Limit: undeck.MaxListLimit}
+	)
+
+	for {
+		var page, err = r.Repo.List(ctx, filter)
+		if err != nil {
+			return n, err
+		}
+
+		for _, d := range page.Decks {
+			if !strings.HasPrefix(d.ID, prefix) {
+				return n, nil
+			}
+
+			n++
+		}
+
+		if !strings.HasPrefix(page.Next, prefix) {
+			return n, nil
+		}
+
+		filter.Cursor = page.Next
+	}
+}
+
+// in prefixes the ids of a deck going to the decorated repo
+func in(prefix string, d undeck.Deck) undeck.Deck {
+	d.ID = prefix + d.ID
+
+	if d.ParentID != "" {
+		d.ParentID = prefix + d.ParentID
+	}
+
+	return d
+}
+
+// out strips the prefix from the ids of a deck coming from the decorated repo
+func out(prefix string, d undeck.Deck) undeck.Deck {
+	d.ID = strings.TrimPrefix(d.ID, prefix)
+	d.ParentID = strings.TrimPrefix(d.ParentID, prefix)
+
+	return d
+}
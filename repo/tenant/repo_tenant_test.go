@@ -0,0 +1,232 @@
+package tenant
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/history"
+	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/repotest"
+	"testing"
+	"time"
+)
+
+// scoped repo runs every call in the tenant "acme", for the conformance suite which has no tenant in its context
+type scoped struct {
+	*Repo
+}
+
+func (s scoped) ctx(ctx context.Context) context.Context {
+	return repo.WithTenant(ctx, "acme")
+}
+
+func (s scoped) Create(ctx context.Context) (undeck.Deck, error) {
+	return s.Repo.Create(s.ctx(ctx))
+}
+
+func (s scoped) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	return s.Repo.Save(s.ctx(ctx), deck)
+}
+
+func (s scoped) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	return s.Repo.Find(s.ctx(ctx), id)
+}
+
+func (s scoped) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	return s.Repo.Update(s.ctx(ctx), id, fn)
+}
+
+func (s scoped) Delete(ctx context.Context, id string) error {
+	return s.Repo.Delete(s.ctx(ctx), id)
+}
+
+func (s scoped) List(ctx context.Context, filter undeck.ListFilter) (undeck.ListPage, error) {
+	return s.Repo.List(s.ctx(ctx), filter)
+}
+
+func TestRepo_Isolation(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		acme  = repo.WithTenant(ctx, "acme")
+		umbra = repo.WithTenant(ctx, "umbra")
+		inner = memory.New()
+		r     = New(inner)
+	)
+
+	// decks of other tenants sort both before and after those of acme
+	for _, tc := range []context.Context{repo.WithTenant(ctx, "a"), acme, umbra} {
+		for _, id := range []string{"1", "2", "3"} {
+			if _, err := r.Save(tc, undeck.Deck{ID: id, System: french.System}.Add(french.All()...)); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+		}
+	}
+
+	if _, err := r.Update(umbra, "2", func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(10)
+		return d, err
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if d, err := r.Find(acme, "2"); err != nil || d.ID != "2" || d.Remaining() != 52 {
+		t.Errorf("Find() = deck %q with %d cards (%v), want the untouched deck of acme", d.ID, d.Remaining(), err)
+	}
+
+	if err := r.Delete(umbra, "3"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err := r.Find(acme, "3"); err != nil {
+		t.Errorf("Find() deck deleted by another tenant error = %v", err)
+	}
+
+	if _, err := inner.Find(ctx, "umbra:2"); err != nil {
+		t.Errorf("Find() in the decorated repo error = %v, want the deck stored under the tenant", err)
+	}
+
+	var (
+		got    []string
+		filter = undeck.ListFilter{Limit: 2}
+	)
+
+	for {
+		var page, err = r.List(acme, filter)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		for _, d := range page.Decks {
+			got = append(got, d.ID)
+		}
+
+		if page.Next == "" {
+			break
+		}
+
+		filter.Cursor = page.Next
+	}
+
+	if len(got) != 3 || got[0] != "1" || got[1] != "2" || got[2] != "3" {
+		t.Errorf("List() = %v, want the 3 decks of acme", got)
+	}
+}
+
+func TestRepo_Tenant(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = New(memory.New())
+	)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{name: "none", ctx: ctx, want: ErrNoTenant},
+		{name: "invalid", ctx: repo.WithTenant(ctx, "acme:1"), want: repo.ErrInvalidTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Create(tt.ctx); err != tt.want {
+				t.Errorf("Create() error = %v, want %v", err, tt.want)
+			}
+
+			if _, err := r.Save(tt.ctx, undeck.Deck{ID: "1"}); err != tt.want {
+				t.Errorf("Save() error = %v, want %v", err, tt.want)
+			}
+
+			if _, err := r.Find(tt.ctx, "1"); err != tt.want {
+				t.Errorf("Find() error = %v, want %v", err, tt.want)
+			}
+
+			if _, err := r.List(tt.ctx, undeck.ListFilter{}); err != tt.want {
+				t.Errorf("List() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepo_Quota(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		acme  = repo.WithTenant(ctx, "acme")
+		umbra = repo.WithTenant(ctx, "umbra")
+		vip   = repo.WithTenant(ctx, "vip")
+		now   = time.Now()
+		inner = memory.New(memory.WithTTL(time.Hour, 0), memory.WithClock(func() time.Time { return now }))
+		r     = New(inner, WithQuota(2), WithTenantQuota("vip", 0))
+	)
+
+	r.Save(acme, undeck.Deck{ID: "1"})
+	r.Save(umbra, undeck.Deck{ID: "1"})
+
+	d, err := r.Save(acme, undeck.Deck{ID: "2"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if _, err = r.Save(acme, undeck.Deck{ID: "3"}); err != ErrQuotaExceeded {
+		t.Errorf("Save() over quota error = %v, want %v", err, ErrQuotaExceeded)
+	}
+
+	if _, err = r.Save(acme, d); err != nil {
+		t.Errorf("Save() of a stored deck over quota error = %v", err)
+	}
+
+	if _, err = r.Save(umbra, undeck.Deck{ID: "2"}); err != nil {
+		t.Errorf("Save() in another tenant error = %v", err)
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err = r.Save(vip, undeck.Deck{ID: id}); err != nil {
+			t.Errorf("Save() without quota error = %v", err)
+		}
+	}
+
+	if err = r.Delete(acme, "1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	if _, err = r.Save(acme, undeck.Deck{ID: "3"}); err != nil {
+		t.Errorf("Save() after a delete error = %v", err)
+	}
+
+	// expired decks are not deleted through the repo, they are found out when counting again
+	now = now.Add(2 * time.Hour)
+
+	if _, err = r.Save(acme, undeck.Deck{ID: "4"}); err != nil {
+		t.Errorf("Save() after decks expired error = %v", err)
+	}
+}
+
+func TestRepo_History(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		acme  = repo.WithTenant(ctx, "acme")
+		umbra = repo.WithTenant(ctx, "umbra")
+		r     = history.New(New(memory.New()))
+	)
+
+	r.Save(acme, undeck.Deck{ID: "1", System: french.System}.Add(french.All()...))
+	r.Save(umbra, undeck.Deck{ID: "1", System: french.System}.Add(french.All()...))
+	r.Delete(umbra, "1")
+
+	if events, err := r.History(acme, "1"); err != nil || len(events) != 1 {
+		t.Errorf("History() = %d events (%v), want only the creation in acme", len(events), err)
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return scoped{New(memory.New(), WithQuota(1000))}
+	})
+}
+
+func TestRepo_Collisions(t *testing.T) {
+	repotest.RunCollisions(t, func(t *testing.T, gen repo.IDGenerator) undeck.Repo {
+		return scoped{New(memory.New(memory.WithIDGenerator(gen)))}
+	})
+}
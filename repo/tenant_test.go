@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidTenant(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenant  string
+		wantErr bool
+	}{
+		{name: "simple", tenant: "acme"},
+		{name: "punctuation", tenant: "team-1.red_2"},
+		{name: "longest", tenant: strings.Repeat("a", MaxTenantLength)},
+		{name: "empty", tenant: "", wantErr: true},
+		{name: "too long", tenant: strings.Repeat("a", MaxTenantLength+1), wantErr: true},
+		{name: "colon", tenant: "acme:blue", wantErr: true},
+		{name: "space", tenant: "acme blue", wantErr: true},
+		{name: "unicode", tenant: "acmé", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidTenant(tt.tenant); (err != nil) != tt.wantErr {
+				t.Errorf("ValidTenant() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	var ctx = context.Background()
+
+	if got := Key(ctx, "1"); got != "1" {
+		t.Errorf("Key() without tenant = %q, want %q", got, "1")
+	}
+
+	if got := Key(WithTenant(ctx, "acme"), "1"); got != "acme:1" {
+		t.Errorf("Key() = %q, want %q", got, "acme:1")
+	}
+}
@@ -0,0 +1,314 @@
+// Package undo lets changes to decks be undone and redone by keeping snapshots of their previous states
+package undo
+
+import (
+	"context"
+	"errors"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/repo"
+	"sync"
+	"time"
+)
+
+// DefaultDepth is the number of changes which can be undone when no depth is given
+const DefaultDepth = 10
+
+var (
+	// ErrNothingToUndo indicates that a deck has no change left to undo
+	ErrNothingToUndo = errors.New("nothing to undo")
+
+	// ErrNothingToRedo indicates that a deck has no undone change to redo
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// CheckFunc checks the state a deck is in before a change to it is undone or redone, which is refused if it returns
+// an error
+type CheckFunc func(undeck.Deck) error
+
+type checkKey struct{}
+
+// WithCheck returns a context whose undos and redos only go through if fn accepts the deck, e.g. to check its
+// version. fn is run within the same update as the undo or redo
+func WithCheck(ctx context.Context, fn CheckFunc) context.Context {
+	return context.WithValue(ctx, checkKey{}, fn)
+}
+
+// checkOf ctx, nil if undos and redos are not checked
+func checkOf(ctx context.Context) CheckFunc {
+	var fn, _ = ctx.Value(checkKey{}).(CheckFunc)
+	return fn
+}
+
+// New decorates repo so that up to depth changes per deck can be undone, DefaultDepth if depth is not positive.
+// Snapshots are kept in memory
+func New(repo undeck.Repo, depth int) *Repo {
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+
+	return &Repo{
+		Repo:   repo,
+		depth:  depth,
+		stacks: make(map[string]*stacks),
+	}
+}
+
+// Repo keeps the states decks were in before each change saved through it.
+// Undo restores the previous state of a deck and saves it as a new version, any other change clears what can be redone
+type Repo struct {
+	undeck.Repo
+
+	depth  int
+	mu     sync.Mutex
+	stacks map[string]*stacks
+}
+
+// stacks of the states of a deck
+type stacks struct {
+	mu   sync.Mutex
+	undo []undeck.Deck
+	redo []undeck.Deck
+	last *undeck.Deck
+
+	// tenant and id of the deck, to find it when pruning
+	tenant, id string
+
+	// pruned stacks are no longer in the map, changes must be kept in new stacks
+	pruned bool
+}
+
+// Unwrap returns the decorated repo
+func (r *Repo) Unwrap() undeck.Repo {
+	return r.Repo
+}
+
+func (r *Repo) Save(ctx context.Context, deck undeck.Deck) (undeck.Deck, error) {
+	var s = r.lock(ctx, deck.ID)
+	defer s.mu.Unlock()
+
+	var d, err = r.Repo.Save(ctx, deck)
+	if err != nil {
+		return d, err
+	}
+
+	if s.last != nil {
+		r.push(s, *s.last)
+	}
+
+	s.redo = nil
+	s.last = snapshot(d)
+
+	return d, nil
+}
+
+func (r *Repo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	var (
+		prev undeck.Deck
+		s    = r.lock(ctx, id)
+	)
+
+	defer s.mu.Unlock()
+
+	var d, err = r.Repo.Update(ctx, id, func(d undeck.Deck) (undeck.Deck, error) {
+		prev = d.Duplicate()
+		return fn(d)
+	})
+
+	if err != nil {
+		return d, err
+	}
+
+	r.push(s, prev)
+	s.redo = nil
+	s.last = snapshot(d)
+
+	return d, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id string) error {
+	var s = r.lock(ctx, id)
+	defer s.mu.Unlock()
+
+	if err := r.Repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s.pruned = true
+	delete(r.stacks, repo.Key(ctx, id))
+
+	return nil
+}
+
+// Undo the last change to a deck, it is refused for a hidden deck if drawn cards would go back to the deck
+func (r *Repo) Undo(ctx context.Context, id string) (undeck.Deck, error) {
+	var s = r.lock(ctx, id)
+	defer s.mu.Unlock()
+
+	if len(s.undo) == 0 {
+		return undeck.Deck{}, ErrNothingToUndo
+	}
+
+	var prev, d, err = r.restore(ctx, id, s.undo[len(s.undo)-1])
+	if err != nil {
+		return d, err
+	}
+
+	s.undo = s.undo[:len(s.undo)-1]
+	s.redo = append(s.redo, prev)
+	s.last = snapshot(d)
+
+	return d, nil
+}
+
+// Redo the last change undone, it is refused for a hidden deck if drawn cards would go back to the deck
+func (r *Repo) Redo(ctx context.Context, id string) (undeck.Deck, error) {
+	var s = r.lock(ctx, id)
+	defer s.mu.Unlock()
+
+	if len(s.redo) == 0 {
+		return undeck.Deck{}, ErrNothingToRedo
+	}
+
+	var prev, d, err = r.restore(ctx, id, s.redo[len(s.redo)-1])
+	if err != nil {
+		return d, err
+	}
+
+	s.redo = s.redo[:len(s.redo)-1]
+	r.push(s, prev)
+	s.last = snapshot(d)
+
+	return d, nil
+}
+
+// restore a deck to an earlier state, returning the state it was in before
+func (r *Repo) restore(ctx context.Context, id string, to undeck.Deck) (undeck.Deck, undeck.Deck, error) {
+	var prev undeck.Deck
+
+	var d, err = r.Repo.Update(ctx, id, func(d undeck.Deck) (undeck.Deck, error) {
+		if check := checkOf(ctx); check != nil {
+			if err := check(d); err != nil {
+				return d, err
+			}
+		}
+
+		if d.Hidden && reveals(d, to) {
+			return d, undeck.ErrDeckHidden
+		}
+
+		prev = d.Duplicate()
+
+		return to.Duplicate(), nil
+	})
+
+	return prev, d, err
+}
+
+// push a state to undo, forgetting the oldest ones beyond the depth
+func (r *Repo) push(s *stacks, d undeck.Deck) {
+	s.undo = append(s.undo, d)
+
+	if len(s.undo) > r.depth {
+		s.undo = append([]undeck.Deck(nil), s.undo[len(s.undo)-r.depth:]...)
+	}
+}
+
+// lock the stacks of a deck, created on first use. The caller must unlock them
+func (r *Repo) lock(ctx context.Context, id string) *stacks {
+	var key = repo.Key(ctx, id)
+
+	for {
+		r.mu.Lock()
+
+		var s, ok = r.stacks[key]
+		if !ok {
+			s = &stacks{tenant: repo.Tenant(ctx), id: id}
+			r.stacks[key] = s
+		}
+
+		r.mu.Unlock()
+
+		s.mu.Lock()
+
+		if !s.pruned {
+			return s
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// Prune the stacks of decks which are no longer found or have expired, and those left empty by failed changes
+func (r *Repo) Prune(ctx context.Context) {
+	r.mu.Lock()
+
+	var all = make(map[string]*stacks, len(r.stacks))
+	for key, s := range r.stacks {
+		all[key] = s
+	}
+
+	r.mu.Unlock()
+
+	for key, s := range all {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// peeking keeps the janitor from keeping decks alive by finding them
+		var _, err = r.Repo.Find(repo.WithPeek(repo.WithTenant(ctx, s.tenant)), s.id)
+		if err != undeck.ErrDeckNotFound && err != undeck.ErrDeckExpired {
+			continue
+		}
+
+		r.mu.Lock()
+
+		// stacks being changed belong to a deck which is back
+		if s.mu.TryLock() {
+			s.pruned = true
+			delete(r.stacks, key)
+			s.mu.Unlock()
+		}
+
+		r.mu.Unlock()
+	}
+}
+
+// Janitor prunes the stacks every interval until ctx is done
+func (r *Repo) Janitor(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Prune(ctx)
+		}
+	}
+}
+
+func snapshot(d undeck.Deck) *undeck.Deck {
+	var dup = d.Duplicate()
+	return &dup
+}
+
+// reveals reports whether going from the deck to the state would put cards drawn from the deck back among its remaining cards
+func reveals(d, state undeck.Deck) bool {
+	var drawn = make(map[string]bool)
+
+	for _, c := range d.Drawn() {
+		drawn[c.String()] = true
+	}
+
+	for _, c := range state.Cards() {
+		if drawn[c.String()] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package undo
+
+import (
+	"context"
+	"go.fluxy.net/undeck"
+	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/repotest"
+	"go.fluxy.net/undeck/repo/tenant"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func draw(n int) undeck.UpdateFunc {
+	return func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(n)
+		return d, err
+	}
+}
+
+func TestRepo_UndoRedo(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = New(memory.New(), 2)
+	)
+
+	d0, err := r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if _, err = r.Undo(ctx, "1"); err != ErrNothingToUndo {
+		t.Errorf("Undo() of a new deck error = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	d1, _ := r.Update(ctx, "1", draw(1))
+	d2, _ := r.Update(ctx, "1", draw(5))
+
+	got, err := r.Undo(ctx, "1")
+	if err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+
+	internal.AssertCardSlicesEqual(t, d1.Cards(), got.Cards())
+	internal.AssertCardSlicesEqual(t, d1.Drawn(), got.Drawn())
+
+	if got.Version != d2.Version+1 {
+		t.Errorf("Undo() version = %d, want %d", got.Version, d2.Version+1)
+	}
+
+	if got, err = r.Redo(ctx, "1"); err != nil {
+		t.Fatalf("Redo() error = %v", err)
+	}
+
+	internal.AssertCardSlicesEqual(t, d2.Cards(), got.Cards())
+
+	if _, err = r.Redo(ctx, "1"); err != ErrNothingToRedo {
+		t.Errorf("Redo() twice error = %v, want %v", err, ErrNothingToRedo)
+	}
+
+	// the depth is 2 so the deck can be taken back to d1 but not d0
+	r.Undo(ctx, "1")
+
+	if got, err = r.Undo(ctx, "1"); err != nil {
+		t.Fatalf("Undo() to depth error = %v", err)
+	}
+
+	internal.AssertCardSlicesEqual(t, d0.Cards(), got.Cards())
+
+	if _, err = r.Undo(ctx, "1"); err != ErrNothingToUndo {
+		t.Errorf("Undo() past depth error = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	r.Update(ctx, "1", draw(1))
+
+	if _, err = r.Redo(ctx, "1"); err != ErrNothingToRedo {
+		t.Errorf("Redo() after a change error = %v, want %v", err, ErrNothingToRedo)
+	}
+}
+
+func TestRepo_UndoHidden(t *testing.T) {
+	var (
+		ctx = context.Background()
+		r   = New(memory.New(), 0)
+	)
+
+	if _, err := r.Save(ctx, undeck.Deck{ID: "1", Hidden: true}.Add(french.All()...)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	r.Update(ctx, "1", draw(1))
+
+	if _, err := r.Undo(ctx, "1"); err != undeck.ErrDeckHidden {
+		t.Errorf("Undo() of a draw error = %v, want %v", err, undeck.ErrDeckHidden)
+	}
+
+	r.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+		return d.Shuffle(), nil
+	})
+
+	if _, err := r.Undo(ctx, "1"); err != nil {
+		t.Errorf("Undo() of a shuffle error = %v", err)
+	}
+}
+
+func TestRepo_Prune(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		acme  = repo.WithTenant(ctx, "acme")
+		umbra = repo.WithTenant(ctx, "umbra")
+		now   = time.Now()
+		r     = New(tenant.New(memory.New(memory.WithTTL(time.Hour, time.Hour), memory.WithClock(func() time.Time { return now }))), 0)
+	)
+
+	r.Save(acme, undeck.Deck{ID: "1"}.Add(french.All()...))
+	r.Update(acme, "1", draw(1))
+	r.Save(umbra, undeck.Deck{ID: "1"}.Add(french.All()...))
+
+	// failed changes leave empty stacks behind
+	r.Update(acme, "2", draw(1))
+
+	now = now.Add(30 * time.Minute)
+	r.Update(umbra, "1", draw(1))
+
+	now = now.Add(45 * time.Minute)
+	r.Prune(ctx)
+
+	if len(r.stacks) != 1 {
+		t.Errorf("stacks = %d, want only those of the deck used within the ttl", len(r.stacks))
+	}
+
+	if _, err := r.Undo(umbra, "1"); err != nil {
+		t.Errorf("Undo() after pruning error = %v", err)
+	}
+
+	if _, err := r.Undo(acme, "1"); err != ErrNothingToUndo {
+		t.Errorf("Undo() of an expired deck error = %v, want %v", err, ErrNothingToUndo)
+	}
+}
+
+// TestRepo_JanitorExpiry checks that the janitor looking for expired decks does not keep them alive
+func TestRepo_JanitorExpiry(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		now         atomic.Int64
+		clock       = func() time.Time { return time.Unix(0, now.Load()) }
+		storage     = memory.New(memory.WithTTL(time.Hour, time.Hour), memory.WithClock(clock))
+		r           = New(storage, 0)
+	)
+
+	defer cancel()
+
+	now.Store(time.Now().UnixNano())
+
+	r.Save(ctx, undeck.Deck{ID: "1"}.Add(french.All()...))
+	r.Update(ctx, "1", draw(1))
+
+	go r.Janitor(ctx, time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		now.Add(int64(20 * time.Minute))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := storage.Find(ctx, "1"); err != undeck.ErrDeckExpired {
+		t.Fatalf("Find() after the ttl error = %v, want %v", err, undeck.ErrDeckExpired)
+	}
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := r.Undo(ctx, "1"); err == ErrNothingToUndo {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Undo() of an expired deck error = %v, want %v", err, ErrNothingToUndo)
+		}
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) undeck.Repo {
+		return New(memory.New(), DefaultDepth)
+	})
+}
@@ -0,0 +1,218 @@
+package undeck
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// SchemaVersion of the serialized form of decks written by DeckState
+	SchemaVersion = 1
+
+	// DefaultSystem is the card system of decks which do not name one
+	DefaultSystem = "french"
+)
+
+var (
+	// ErrUnknownSystem indicates that no card system was registered under a name
+	ErrUnknownSystem = errors.New("card system is not registered")
+
+	// ErrUnsupportedSchema indicates that a serialized deck was written by a newer version of the schema
+	ErrUnsupportedSchema = errors.New("deck schema is not supported")
+)
+
+// CardDecoder converts the code of a card back to the card, e.g. AS to the Ace of Spades
+type CardDecoder func(code string) (Card, error)
+
+// CardSystem is a family of cards decks can be made of, e.g. the 52 french cards
+type CardSystem struct {
+	Name string
+
+	// Decode a card from its code
+	Decode CardDecoder
+
+	// All cards of the system in a fixed order, the binary encoding stores cards as their index in it
+	All func() []Card
+
+	all   []Card
+	index map[string]byte
+}
+
+var (
+	systemsMu sync.RWMutex
+	systems   = make(map[string]CardSystem)
+)
+
+// RegisterSystem makes a card system available by name when decks are deserialized, card systems register themselves on init
+func RegisterSystem(s CardSystem) {
+	systemsMu.Lock()
+	defer systemsMu.Unlock()
+
+	if s.All != nil {
+		s.all = s.All()
+	}
+
+	if len(s.all) <= 256 {
+		s.index = make(map[string]byte, len(s.all))
+
+		for i := range s.all {
+			s.index[s.all[i].String()] = byte(i)
+		}
+	}
+
+	systems[s.Name] = s
+}
+
+// System returns the card system registered under name
+func System(name string) (CardSystem, error) {
+	systemsMu.RLock()
+	defer systemsMu.RUnlock()
+
+	var s, ok = systems[name]
+	if !ok {
+		return s, ErrUnknownSystem
+	}
+
+	return s, nil
+}
+
+// DeckState the state of a deck, can be used for serialization. Cards are kept as their codes and rebuilt
+// with the card system registered under System
+type DeckState struct {
+	Schema     int       `json:"schema"`
+	ID         string    `json:"id"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	System     string    `json:"system"`
+	Shuffled   bool      `json:"shuffled"`
+	Hidden     bool      `json:"hidden,omitempty"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	AccessedAt time.Time `json:"accessed_at"`
+	Cards      []string  `json:"cards"`
+	Drawn      []string  `json:"drawn"`
+}
+
+// ToDeckState returns the DeckState representation of a deck in the current schema
+func ToDeckState(d Deck) DeckState {
+	var s = DeckState{
+		Schema:     SchemaVersion,
+		ID:         d.ID,
+		ParentID:   d.ParentID,
+		System:     d.System,
+		Shuffled:   d.IsShuffled,
+		Hidden:     d.Hidden,
+		Version:    d.Version,
+		CreatedAt:  d.CreatedAt,
+		AccessedAt: d.AccessedAt,
+	}
+
+	if s.System == "" {
+		s.System = DefaultSystem
+	}
+
+	for _, c := range d.cards {
+		s.Cards = append(s.Cards, c.String())
+	}
+
+	for _, c := range d.drawn {
+		s.Drawn = append(s.Drawn, c.String())
+	}
+
+	return s
+}
+
+// Migrate the state to the current schema, a state without schema is of the current one. There is a single schema
+// so far, states of newer ones are refused
+func (s DeckState) Migrate() (DeckState, error) {
+	if s.Schema == 0 {
+		s.Schema = SchemaVersion
+	}
+
+	if s.Schema > SchemaVersion {
+		return s, ErrUnsupportedSchema
+	}
+
+	return s, nil
+}
+
+// Deck rebuilds the deck, migrating the state first if it is of an older schema
+func (s DeckState) Deck() (Deck, error) {
+	var (
+		d   Deck
+		err error
+	)
+
+	if s, err = s.Migrate(); err != nil {
+		return d, err
+	}
+
+	if s.System == "" {
+		s.System = DefaultSystem
+	}
+
+	system, err := System(s.System)
+	if err != nil {
+		return d, err
+	}
+
+	d = Deck{
+		ID:         s.ID,
+		ParentID:   s.ParentID,
+		System:     s.System,
+		IsShuffled: s.Shuffled,
+		Hidden:     s.Hidden,
+		Version:    s.Version,
+		CreatedAt:  s.CreatedAt,
+		AccessedAt: s.AccessedAt,
+	}
+
+	if d.cards, err = decodeAll(system.Decode, s.Cards); err != nil {
+		return Deck{}, err
+	}
+
+	if d.drawn, err = decodeAll(system.Decode, s.Drawn); err != nil {
+		return Deck{}, err
+	}
+
+	return d, nil
+}
+
+func decodeAll(decode CardDecoder, codes []string) ([]Card, error) {
+	var cards []Card
+
+	for _, code := range codes {
+		var c, err = decode(code)
+		if err != nil {
+			return nil, err
+		}
+
+		cards = append(cards, c)
+	}
+
+	return cards, nil
+}
+
+// MarshalJSON serializes the deck as its DeckState, the shuffler is not kept
+func (d Deck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ToDeckState(d))
+}
+
+// UnmarshalJSON rebuilds a deck from its DeckState of any schema
+func (d *Deck) UnmarshalJSON(b []byte) error {
+	var s DeckState
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	var deck, err = s.Deck()
+	if err != nil {
+		return err
+	}
+
+	*d = deck
+
+	return nil
+}
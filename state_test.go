@@ -0,0 +1,139 @@
+package undeck
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func init() {
+	RegisterSystem(CardSystem{
+		Name: "test",
+		Decode: func(code string) (Card, error) {
+			if len(code) != 2 || code[1] != 'H' {
+				return Card{}, ErrInvalidSuit
+			}
+
+			return testcard(code[:1], code[:1], "Hearts", "H"), nil
+		},
+		All: func() []Card {
+			var cards []Card
+
+			for _, r := range "A23456789TJQK" {
+				cards = append(cards, testcard(string(r), string(r), "Hearts", "H"))
+			}
+
+			return cards
+		},
+	})
+}
+
+func TestDeck_MarshalJSON(t *testing.T) {
+	var d = Deck{
+		ID:         "1",
+		System:     "test",
+		IsShuffled: true,
+		Version:    3,
+		CreatedAt:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		AccessedAt: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	}.Add(
+		testcard("A", "A", "Hearts", "H"),
+		testcard("K", "K", "Hearts", "H"),
+		testcard("Q", "Q", "Hearts", "H"),
+	)
+
+	d, _, _ = d.Draw(1)
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got Deck
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got.ID != d.ID || got.System != d.System || got.IsShuffled != d.IsShuffled || got.Version != d.Version {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, d)
+	}
+
+	if !got.CreatedAt.Equal(d.CreatedAt) || !got.AccessedAt.Equal(d.AccessedAt) {
+		t.Errorf("Unmarshal() times = %v %v, want %v %v", got.CreatedAt, got.AccessedAt, d.CreatedAt, d.AccessedAt)
+	}
+
+	assertCardSlicesEqual(t, d.Cards(), got.Cards())
+	assertCardSlicesEqual(t, d.Drawn(), got.Drawn())
+}
+
+func TestDeckState_Migrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   DeckState
+		want    DeckState
+		wantErr error
+	}{
+		{
+			name:  "no schema",
+			state: DeckState{ID: "1", System: "test"},
+			want:  DeckState{Schema: SchemaVersion, ID: "1", System: "test"},
+		},
+		{
+			name:  "current schema",
+			state: DeckState{Schema: SchemaVersion, ID: "1", System: "test"},
+			want:  DeckState{Schema: SchemaVersion, ID: "1", System: "test"},
+		},
+		{
+			name:    "newer schema",
+			state:   DeckState{Schema: SchemaVersion + 1, ID: "1"},
+			wantErr: ErrUnsupportedSchema,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.state.Migrate()
+
+			if err != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && (got.Schema != tt.want.Schema || got.ID != tt.want.ID || got.System != tt.want.System) {
+				t.Errorf("Migrate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeckState_Deck(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr error
+	}{
+		{
+			name: "current schema",
+			json: `{"schema":1,"id":"1","system":"test","cards":["AH","KH"],"drawn":["QH"]}`,
+		},
+		{
+			name:    "unknown system",
+			json:    `{"schema":1,"id":"1","system":"tarot","cards":["AH"]}`,
+			wantErr: ErrUnknownSystem,
+		},
+		{
+			name:    "invalid card",
+			json:    `{"schema":1,"id":"1","system":"test","cards":["AS"]}`,
+			wantErr: ErrInvalidSuit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Deck
+
+			if err := json.Unmarshal([]byte(tt.json), &d); err != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
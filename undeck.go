@@ -14,6 +14,21 @@ var (
 
 	// ErrNotEnoughCards indicates that operations on a deck failed because the deck does not contain enough cards
 	ErrNotEnoughCards = errors.New("deck does not contain enough cards")
+
+	// ErrInvalidOrder indicates that a sort order is not known
+	ErrInvalidOrder = errors.New("sort order is not valid")
+
+	// ErrInvalidStack indicates that a stacked deck order is not known
+	ErrInvalidStack = errors.New("stack is not valid")
+
+	// ErrCardNotInStack indicates that a card is not part of a stacked deck order
+	ErrCardNotInStack = errors.New("card is not part of the stack")
+
+	// ErrInvalidPile indicates that a pile of a deck is not known
+	ErrInvalidPile = errors.New("pile is not valid")
+
+	// ErrDeckHidden indicates that an operation would reveal the order of the remaining cards of a hidden deck
+	ErrDeckHidden = errors.New("deck is hidden")
 )
 
 // Rank of a card depending on the game being played, in a 52 french deck: Ace, 2-10, Jack, Queen and King
@@ -1,14 +1,67 @@
 package draw
 
 import (
+	"context"
+	"errors"
 	"go.fluxy.net/undeck"
 	"go.fluxy.net/undeck/cards"
 	"go.fluxy.net/undeck/cards/french"
+	"go.fluxy.net/undeck/cards/lehmer"
+	"go.fluxy.net/undeck/odds"
+	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/history"
+	"go.fluxy.net/undeck/repo/tenant"
+	"go.fluxy.net/undeck/repo/undo"
 	"go.fluxy.net/undeck/web"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
+var (
+	// ErrHistoryNotKept indicates that the repo does not keep the history of decks
+	ErrHistoryNotKept = errors.New("history of decks is not kept")
+
+	// ErrUndoNotKept indicates that the repo does not keep the previous states of decks
+	ErrUndoNotKept = errors.New("undo is not kept")
+)
+
+// historian is a repo keeping the history of decks, such as history.Repo
+type historian interface {
+	History(ctx context.Context, id string) ([]history.Event, error)
+	At(ctx context.Context, id string, seq int) (undeck.Deck, error)
+}
+
+// undoer is a repo keeping the previous states of decks, such as undo.Repo
+type undoer interface {
+	Undo(ctx context.Context, id string) (undeck.Deck, error)
+	Redo(ctx context.Context, id string) (undeck.Deck, error)
+}
+
+// historian layer of the repo, if any
+func (s *Draw) historian() (historian, bool) {
+	for _, l := range repo.Layers(s.repo) {
+		if h, ok := l.(historian); ok {
+			return h, true
+		}
+	}
+
+	return nil, false
+}
+
+// undoer layer of the repo, if any
+func (s *Draw) undoer() (undoer, bool) {
+	for _, l := range repo.Layers(s.repo) {
+		if u, ok := l.(undoer); ok {
+			return u, true
+		}
+	}
+
+	return nil, false
+}
+
 func New(repo undeck.Repo, idGetter web.IDGetter) *Draw {
 	return &Draw{
 		repo:     repo,
@@ -24,10 +77,41 @@ type Draw struct {
 
 type createResponse struct {
 	DeckID    string `json:"deck_id"`
+	ParentID  string `json:"parent_id,omitempty"`
 	Shuffled  bool   `json:"shuffled"`
 	Remaining int    `json:"remaining"`
 }
 
+// newCards for a deck being created, either a list of card codes, a named stack, a share code or all cards by default
+func newCards(query url.Values) ([]undeck.Card, error) {
+	var (
+		given int
+
+		rawCards = query.Get("cards")
+		rawStack = query.Get("stack")
+		rawCode  = query.Get("code")
+	)
+
+	for _, raw := range []string{rawCards, rawStack, rawCode} {
+		if raw != "" {
+			given++
+		}
+	}
+
+	switch {
+	case given > 1:
+		return nil, web.ErrInvalidRequest
+	case rawStack != "":
+		return french.Stack(rawStack)
+	case rawCode != "":
+		return lehmer.Decode(french.All(), rawCode)
+	case rawCards != "":
+		return cards.FromString(french.FromString, rawCards)
+	}
+
+	return french.All(), nil
+}
+
 func (s *Draw) Create(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx   = r.Context()
@@ -39,13 +123,12 @@ func (s *Draw) Create(w http.ResponseWriter, r *http.Request) {
 	)
 
 	if err != nil {
-		web.JsonError(w, http.StatusInternalServerError, err)
+		web.JsonError(w, repoStatus(err), err)
 		return
 	}
 
-	if rawCards := query.Get("cards"); rawCards == "" {
-		deck = deck.Add(french.All()...)
-	} else if cs, err := cards.FromString(french.FromString, rawCards); err == nil {
+	if cs, err := newCards(query); err == nil {
+		deck.System = french.System
 		deck = deck.Add(cs...)
 	} else {
 		web.JsonError(w, http.StatusBadRequest, err)
@@ -62,10 +145,19 @@ func (s *Draw) Create(w http.ResponseWriter, r *http.Request) {
 		deck = deck.Shuffle()
 	}
 
+	if rawHidden := query.Get("hidden"); rawHidden == "" {
+		// ignore it
+	} else if b, err := strconv.ParseBool(rawHidden); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	} else {
+		deck.Hidden = b
+	}
+
 	deck, err = s.repo.Save(ctx, deck)
 
 	if err != nil {
-		web.JsonError(w, http.StatusInternalServerError, err)
+		web.JsonError(w, repoStatus(err), err)
 		return
 	}
 
@@ -78,6 +170,52 @@ func (s *Draw) Create(w http.ResponseWriter, r *http.Request) {
 	web.Json(w, res)
 }
 
+// repoStatus is the http status to reply with for an error from the repo or from updating a deck
+func repoStatus(err error) int {
+	// repos may wrap the error of a request which took too long
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+
+	switch err {
+	case undeck.ErrDeckNotFound, history.ErrEventNotFound:
+		return http.StatusNotFound
+	case undeck.ErrDeckExpired:
+		return http.StatusGone
+	case undeck.ErrVersionConflict:
+		return http.StatusPreconditionFailed
+	case undeck.ErrNotEnoughCards, undeck.ErrInvalidPile, web.ErrInvalidRequest:
+		return http.StatusBadRequest
+	case undeck.ErrDeckHidden:
+		return http.StatusForbidden
+	case undo.ErrNothingToUndo, undo.ErrNothingToRedo:
+		return http.StatusConflict
+	case ErrHistoryNotKept, ErrUndoNotKept:
+		return http.StatusNotImplemented
+	case undeck.ErrIDCollision:
+		return http.StatusServiceUnavailable
+	case tenant.ErrQuotaExceeded:
+		return http.StatusTooManyRequests
+	case tenant.ErrNoTenant:
+		return http.StatusUnauthorized
+	case repo.ErrInvalidTenant:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// ifMatch fails with undeck.ErrVersionConflict if the request has an If-Match header not matching the deck version
+func ifMatch(r *http.Request, deck undeck.Deck) error {
+	var raw = r.Header.Get(web.HeaderIfMatch)
+
+	if raw != "" && !web.MatchETag(raw, web.ETag(deck.Version)) {
+		return undeck.ErrVersionConflict
+	}
+
+	return nil
+}
+
 type openResponse struct {
 	DeckID    string             `json:"deck_id"`
 	Shuffled  bool               `json:"shuffled"`
@@ -99,11 +237,29 @@ func (s *Draw) Open(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if deck, err = s.repo.Find(ctx, id); err == undeck.ErrDeckNotFound {
-		web.JsonError(w, http.StatusNotFound, err)
+	if deck, err = s.find(ctx, id, r.URL.Query().Get("at")); err != nil {
+		web.JsonError(w, repoStatus(err), err)
 		return
-	} else if err != nil {
-		web.JsonError(w, http.StatusInternalServerError, err)
+	}
+
+	var etag = web.ETag(deck.Version)
+
+	w.Header().Set(web.HeaderETag, etag)
+
+	if raw := r.Header.Get(web.HeaderIfNoneMatch); raw != "" && web.MatchWeakETag(raw, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if web.Accepts(r, web.ContentTypeBinary) {
+		if deck.Hidden {
+			web.JsonError(w, repoStatus(undeck.ErrDeckHidden), undeck.ErrDeckHidden)
+		} else if b, err := deck.MarshalBinary(); err != nil {
+			web.JsonError(w, http.StatusInternalServerError, err)
+		} else {
+			web.Print(w, http.StatusOK, web.ContentTypeBinary, b)
+		}
+
 		return
 	}
 
@@ -113,13 +269,56 @@ func (s *Draw) Open(w http.ResponseWriter, r *http.Request) {
 		Remaining: deck.Remaining(),
 	}
 
-	for _, c := range deck.Cards() {
-		res.Cards = append(res.Cards, undeck.ToCardState(c))
+	// the cards of a hidden deck are left out, its summary can be used instead
+	if !deck.Hidden {
+		for _, c := range deck.Cards() {
+			res.Cards = append(res.Cards, undeck.ToCardState(c))
+		}
 	}
 
 	web.Json(w, res)
 }
 
+// find the deck, as it was right after the event with sequence number at if given
+func (s *Draw) find(ctx context.Context, id, at string) (undeck.Deck, error) {
+	if at == "" {
+		return s.repo.Find(ctx, id)
+	}
+
+	var h, ok = s.historian()
+	if !ok {
+		return undeck.Deck{}, ErrHistoryNotKept
+	}
+
+	var seq, err = strconv.Atoi(at)
+	if err != nil {
+		return undeck.Deck{}, web.ErrInvalidRequest
+	}
+
+	if err = s.revealable(ctx, id); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return h.At(ctx, id, seq)
+}
+
+// revealable fails with undeck.ErrDeckHidden if the deck is hidden, or with the error finding it. Deleted decks are
+// revealable so that their history remains available, the history itself refuses those which were hidden
+func (s *Draw) revealable(ctx context.Context, id string) error {
+	var d, err = s.repo.Find(ctx, id)
+
+	switch {
+	case err == undeck.ErrDeckNotFound:
+		return nil
+	case err != nil:
+		return err
+	case d.Hidden:
+		return undeck.ErrDeckHidden
+	}
+
+	return nil
+}
+
 type drawResponse struct {
 	Cards []undeck.CardState `json:"cards"`
 }
@@ -147,33 +346,553 @@ func (s *Draw) Draw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if d, err := s.repo.Find(ctx, id); err == nil {
-		deck = d
-	} else if err == undeck.ErrDeckNotFound {
+	deck, err = s.repo.Update(ctx, id, func(deck undeck.Deck) (undeck.Deck, error) {
+		var err = ifMatch(r, deck)
+		if err != nil {
+			return deck, err
+		}
+
+		deck, cardlist, err = deck.Draw(count)
+
+		return deck, err
+	})
+
+	if err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	for i := range cardlist {
+		res.Cards = append(res.Cards, undeck.ToCardState(cardlist[i]))
+	}
+
+	w.Header().Set(web.HeaderETag, web.ETag(deck.Version))
+	web.Json(w, res)
+}
+
+// Sort the remaining cards of a deck, or its drawn cards with pile=drawn
+func (s *Draw) Sort(w http.ResponseWriter, r *http.Request) {
+	var (
+		order undeck.Order
+		deck  undeck.Deck
+		res   createResponse
+
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if raw := r.URL.Query().Get("by"); raw == "" {
+		order = french.NewDeckOrder
+	} else if order, err = french.OrderFromString(raw); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var pile = undeck.Pile(r.URL.Query().Get("pile"))
+	if pile == "" {
+		pile = undeck.PileDeck
+	}
+
+	deck, err = s.repo.Update(history.WithEvent(ctx, history.Sorted), id, func(deck undeck.Deck) (undeck.Deck, error) {
+		if err := ifMatch(r, deck); err != nil {
+			return deck, err
+		}
+
+		// the order of hidden decks is not to be changed through their cards, see Code
+		if deck.Hidden {
+			return deck, undeck.ErrDeckHidden
+		}
+
+		return deck.SortPile(pile, order)
+	})
+
+	if err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	res = createResponse{
+		DeckID:    deck.ID,
+		Shuffled:  deck.IsShuffled,
+		Remaining: deck.Remaining(),
+	}
+
+	w.Header().Set(web.HeaderETag, web.ETag(deck.Version))
+	web.Json(w, res)
+}
+
+type stackResponse struct {
+	Stack    string `json:"stack"`
+	Code     string `json:"code"`
+	Position int    `json:"position"`
+}
+
+// Stack looks up the position of a card in a named stack
+func (s *Draw) Stack(w http.ResponseWriter, r *http.Request) {
+	var (
+		card     undeck.Card
+		position int
+		err      error
+
+		query = r.URL.Query()
+		name  = query.Get("name")
+	)
+
+	if card, err = french.FromString(query.Get("card")); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if position, err = french.StackPosition(name, card); err == undeck.ErrInvalidStack {
 		web.JsonError(w, http.StatusNotFound, err)
 		return
-	} else {
-		web.JsonError(w, http.StatusInternalServerError, err)
+	} else if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	deck, cardlist, err = deck.Draw(count)
-	if err == undeck.ErrNotEnoughCards {
+	web.Json(w, stackResponse{
+		Stack:    strings.ToLower(name),
+		Code:     card.String(),
+		Position: position,
+	})
+}
+
+type codeResponse struct {
+	DeckID string `json:"deck_id"`
+	Code   string `json:"code"`
+}
+
+// Code returns a compact share code of the order of the remaining cards
+func (s *Draw) Code(w http.ResponseWriter, r *http.Request) {
+	var (
+		deck undeck.Deck
+		code string
+
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
 		web.JsonError(w, http.StatusBadRequest, err)
 		return
+	}
+
+	if deck, err = s.repo.Find(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	if deck.Hidden {
+		web.JsonError(w, repoStatus(undeck.ErrDeckHidden), undeck.ErrDeckHidden)
+		return
+	}
+
+	if code, err = lehmer.Encode(french.All(), deck.Cards()); err == lehmer.ErrNotPermutation {
+		web.JsonError(w, http.StatusUnprocessableEntity, err)
+		return
 	} else if err != nil {
 		web.JsonError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	_, err = s.repo.Save(ctx, deck)
+	web.Json(w, codeResponse{
+		DeckID: deck.ID,
+		Code:   code,
+	})
+}
+
+type oddsResponse struct {
+	DeckID      string  `json:"deck_id"`
+	Remaining   int     `json:"remaining"`
+	Matching    int     `json:"matching"`
+	Draws       int     `json:"draws"`
+	AtLeast     int     `json:"at_least"`
+	Probability float64 `json:"probability"`
+	Fraction    string  `json:"fraction"`
+}
+
+// oddsMatcher from the suit, rank and cards query parameters, cards must satisfy all of them
+func oddsMatcher(query url.Values) (odds.Matcher, error) {
+	var m []odds.Matcher
+
+	if raw := query.Get("suit"); raw != "" {
+		m = append(m, odds.Suit(raw))
+	}
+
+	if raw := query.Get("rank"); raw != "" {
+		m = append(m, odds.Rank(raw))
+	}
+
+	if raw := query.Get("cards"); raw == "" {
+		// ignore it
+	} else if cs, err := cards.FromString(french.FromString, raw); err != nil {
+		return nil, err
+	} else {
+		var codes []string
+
+		for i := range cs {
+			codes = append(codes, cs[i].String())
+		}
+
+		m = append(m, odds.Codes(codes...))
+	}
+
+	if len(m) == 0 {
+		return nil, odds.ErrInvalidQuery
+	}
+
+	return odds.All(m...), nil
+}
+
+// Odds of drawing matching cards from the remaining ones, without revealing their order
+func (s *Draw) Odds(w http.ResponseWriter, r *http.Request) {
+	var (
+		deck    undeck.Deck
+		matcher odds.Matcher
+		o       odds.Odds
+
+		draws   = 1
+		atLeast = 1
+		query   = r.URL.Query()
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
 	if err != nil {
-		web.JsonError(w, http.StatusInternalServerError, err)
+		web.JsonError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	for i := range cardlist {
-		res.Cards = append(res.Cards, undeck.ToCardState(cardlist[i]))
+	if matcher, err = oddsMatcher(query); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if raw := query.Get("draws"); raw == "" {
+		// ignore it
+	} else if draws, err = strconv.Atoi(raw); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if raw := query.Get("at_least"); raw == "" {
+		// ignore it
+	} else if atLeast, err = strconv.Atoi(raw); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if deck, err = s.repo.Find(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	if o, err = odds.AtLeast(deck, matcher, draws, atLeast); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	web.Json(w, oddsResponse{
+		DeckID:      deck.ID,
+		Remaining:   o.Remaining,
+		Matching:    o.Matching,
+		Draws:       o.Draws,
+		AtLeast:     o.AtLeast,
+		Probability: o.Float(),
+		Fraction:    o.Probability.String(),
+	})
+}
+
+type summaryResponse struct {
+	DeckID    string             `json:"deck_id"`
+	Shuffled  bool               `json:"shuffled"`
+	Remaining int                `json:"remaining"`
+	Suits     map[string]int     `json:"suits"`
+	Ranks     map[string]int     `json:"ranks"`
+	Drawn     []undeck.CardState `json:"drawn"`
+}
+
+// Summary of the remaining cards by suit and rank along with the cards drawn, without revealing the order
+func (s *Draw) Summary(w http.ResponseWriter, r *http.Request) {
+	var (
+		deck undeck.Deck
+		res  summaryResponse
+
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if deck, err = s.repo.Find(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	res = summaryResponse{
+		DeckID:    deck.ID,
+		Shuffled:  deck.IsShuffled,
+		Remaining: deck.Remaining(),
+		Suits:     make(map[string]int),
+		Ranks:     make(map[string]int),
+	}
+
+	for _, c := range deck.Cards() {
+		res.Suits[c.Suit.String()]++
+		res.Ranks[c.Rank.String()]++
+	}
+
+	for _, c := range deck.Drawn() {
+		res.Drawn = append(res.Drawn, undeck.ToCardState(c))
+	}
+
+	web.Json(w, res)
+}
+
+// Delete a deck, only if it is still at the version of If-Match when set
+func (s *Draw) Delete(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if r.Header.Get(web.HeaderIfMatch) != "" {
+		ctx = undeck.WithDeleteCheck(ctx, func(d undeck.Deck) error {
+			return ifMatch(r, d)
+		})
+	}
+
+	if err = s.repo.Delete(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	web.Json(w, web.Response{Message: "deck deleted"})
+}
+
+// Fork a deck into a new deck with the same remaining cards, and the same drawn cards with piles=true, linked back
+// to it. Hidden decks are not forked since the history of the fork would reveal their order
+func (s *Draw) Fork(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx         = r.Context()
+		deck, child undeck.Deck
+		piles       bool
+
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if raw := r.URL.Query().Get("piles"); raw == "" {
+		// ignore it
+	} else if piles, err = strconv.ParseBool(raw); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if deck, err = s.repo.Find(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	if deck.Hidden {
+		web.JsonError(w, repoStatus(undeck.ErrDeckHidden), undeck.ErrDeckHidden)
+		return
+	}
+
+	if child, err = s.repo.Create(ctx); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	if child, err = s.repo.Save(ctx, deck.Fork(child, piles)); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	w.Header().Set(web.HeaderETag, web.ETag(child.Version))
+
+	web.Json(w, createResponse{
+		DeckID:    child.ID,
+		ParentID:  child.ParentID,
+		Shuffled:  child.IsShuffled,
+		Remaining: child.Remaining(),
+	})
+}
+
+type historyResponse struct {
+	DeckID string          `json:"deck_id"`
+	Events []history.Event `json:"events"`
+}
+
+// History of the events of a deck, it reveals every order the deck has been in
+func (s *Draw) History(w http.ResponseWriter, r *http.Request) {
+	var (
+		ctx     = r.Context()
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var h, ok = s.historian()
+	if !ok {
+		web.JsonError(w, repoStatus(ErrHistoryNotKept), ErrHistoryNotKept)
+		return
+	}
+
+	if err = s.revealable(ctx, id); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	events, err := h.History(ctx, id)
+	if err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	web.Json(w, historyResponse{DeckID: id, Events: events})
+}
+
+// Undo the last change to a deck, only if it is still at the version of If-Match when set
+func (s *Draw) Undo(w http.ResponseWriter, r *http.Request) {
+	s.undo(w, r, history.Undone, undoer.Undo)
+}
+
+// Redo the last change undone, only if the deck is still at the version of If-Match when set
+func (s *Draw) Redo(w http.ResponseWriter, r *http.Request) {
+	s.undo(w, r, history.Redone, undoer.Redo)
+}
+
+func (s *Draw) undo(w http.ResponseWriter, r *http.Request, event history.EventType, fn func(u undoer, ctx context.Context, id string) (undeck.Deck, error)) {
+	var (
+		ctx     = history.WithEvent(r.Context(), event)
+		id, err = s.idGetter(r)
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var u, ok = s.undoer()
+	if !ok {
+		web.JsonError(w, repoStatus(ErrUndoNotKept), ErrUndoNotKept)
+		return
+	}
+
+	ctx = undo.WithCheck(ctx, func(d undeck.Deck) error {
+		return ifMatch(r, d)
+	})
+
+	deck, err := fn(u, ctx, id)
+	if err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	w.Header().Set(web.HeaderETag, web.ETag(deck.Version))
+
+	web.Json(w, createResponse{
+		DeckID:    deck.ID,
+		Shuffled:  deck.IsShuffled,
+		Remaining: deck.Remaining(),
+	})
+}
+
+type listResponse struct {
+	Decks      []createResponse `json:"decks"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+// listFilter from the cursor, limit, created_before, min_remaining and max_remaining query parameters
+func listFilter(query url.Values) (undeck.ListFilter, error) {
+	var (
+		err    error
+		filter = undeck.ListFilter{Cursor: query.Get("cursor")}
+	)
+
+	if raw := query.Get("limit"); raw == "" {
+		// ignore it
+	} else if filter.Limit, err = strconv.Atoi(raw); err != nil {
+		return filter, err
+	}
+
+	if raw := query.Get("created_before"); raw == "" {
+		// ignore it
+	} else if filter.CreatedBefore, err = time.Parse(time.RFC3339, raw); err != nil {
+		return filter, err
+	}
+
+	if raw := query.Get("min_remaining"); raw == "" {
+		// ignore it
+	} else if n, err := strconv.Atoi(raw); err != nil {
+		return filter, err
+	} else {
+		filter.MinRemaining = &n
+	}
+
+	if raw := query.Get("max_remaining"); raw == "" {
+		// ignore it
+	} else if n, err := strconv.Atoi(raw); err != nil {
+		return filter, err
+	} else {
+		filter.MaxRemaining = &n
+	}
+
+	return filter, nil
+}
+
+// List decks a page at a time
+func (s *Draw) List(w http.ResponseWriter, r *http.Request) {
+	var (
+		page undeck.ListPage
+		res  listResponse
+
+		ctx         = r.Context()
+		filter, err = listFilter(r.URL.Query())
+	)
+
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if page, err = s.repo.List(ctx, filter); err != nil {
+		web.JsonError(w, repoStatus(err), err)
+		return
+	}
+
+	res.NextCursor = page.Next
+	res.Decks = []createResponse{}
+
+	for _, d := range page.Decks {
+		res.Decks = append(res.Decks, createResponse{
+			DeckID:    d.ID,
+			ParentID:  d.ParentID,
+			Shuffled:  d.IsShuffled,
+			Remaining: d.Remaining(),
+		})
 	}
 
 	web.Json(w, res)
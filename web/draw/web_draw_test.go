@@ -1,15 +1,23 @@
 package draw
 
 import (
+	"context"
+	"encoding/json"
 	"go.fluxy.net/undeck"
 	"go.fluxy.net/undeck/cards"
 	"go.fluxy.net/undeck/cards/french"
 	"go.fluxy.net/undeck/internal"
 	"go.fluxy.net/undeck/repo"
+	"go.fluxy.net/undeck/repo/history"
 	"go.fluxy.net/undeck/repo/memory"
+	"go.fluxy.net/undeck/repo/tenant"
+	"go.fluxy.net/undeck/repo/undo"
 	"go.fluxy.net/undeck/web"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 )
 
 type fields struct {
@@ -24,7 +32,10 @@ type test struct {
 }
 
 func TestDraw_Create(t *testing.T) {
-	var fakeFrenchShuffled = french.All()
+	var (
+		fakeFrenchShuffled = french.All()
+		mnemonica, _       = french.Stack("mnemonica")
+	)
 
 	fakeFrenchShuffled[0], fakeFrenchShuffled[1] = fakeFrenchShuffled[1], fakeFrenchShuffled[0]
 
@@ -38,7 +49,7 @@ func TestDraw_Create(t *testing.T) {
 				idGetter: nil,
 			},
 			after: memory.NewWith(
-				nil, undeck.OneTwoSwapShuffler, undeck.Deck{ID: "1"}.Add(french.All()...),
+				nil, undeck.OneTwoSwapShuffler, undeck.Deck{ID: "1", Version: 1}.Add(french.All()...),
 			),
 			http: internal.HttpTest{
 				Name:    "no arguments",
@@ -95,7 +106,7 @@ func TestDraw_Create(t *testing.T) {
 			},
 			after: memory.NewWith(
 				nil, undeck.OneTwoSwapShuffler,
-				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "2S,3D,4C,5H")...),
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "2S,3D,4C,5H")...),
 			),
 			http: internal.HttpTest{
 				Name:    "card list only",
@@ -115,6 +126,36 @@ func TestDraw_Create(t *testing.T) {
 				},
 			},
 		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Hidden: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "2S,3D,4C,5H")...),
+			),
+			http: internal.HttpTest{
+				Name:    "hidden",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?cards=2S,3D,4C,5H&hidden=true",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":4}`,
+				},
+			},
+		},
 		{
 			fields: fields{
 				repo: memory.NewWith(
@@ -152,7 +193,7 @@ func TestDraw_Create(t *testing.T) {
 			},
 			after: memory.NewWith(
 				nil, undeck.OneTwoSwapShuffler,
-				undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(fakeFrenchShuffled...),
+				undeck.Deck{ID: "1", Version: 1, IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(fakeFrenchShuffled...),
 			),
 			http: internal.HttpTest{
 				Name:    "shuffle only",
@@ -182,7 +223,7 @@ func TestDraw_Create(t *testing.T) {
 			},
 			after: memory.NewWith(
 				nil, undeck.OneTwoSwapShuffler,
-				undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "9D,TS,8C,7H")...),
+				undeck.Deck{ID: "1", Version: 1, IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "9D,TS,8C,7H")...),
 			),
 			http: internal.HttpTest{
 				Name:    "card list and shuffle",
@@ -202,6 +243,147 @@ func TestDraw_Create(t *testing.T) {
 				},
 			},
 		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(mnemonica...),
+			),
+			http: internal.HttpTest{
+				Name:    "stack",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?stack=mnemonica",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":52}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(nil, undeck.OneTwoSwapShuffler),
+			http: internal.HttpTest{
+				Name:    "unknown stack",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?stack=joker",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"stack is not valid"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(nil, undeck.OneTwoSwapShuffler),
+			http: internal.HttpTest{
+				Name:    "card list and stack",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?stack=aronson&cards=AS",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"request is invalid"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,3S,4S")...),
+			),
+			http: internal.HttpTest{
+				Name:    "share code",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?code=BA",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":4}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					repo.Sequential("1"),
+					undeck.OneTwoSwapShuffler,
+				),
+				idGetter: nil,
+			},
+			after: memory.NewWith(nil, undeck.OneTwoSwapShuffler),
+			http: internal.HttpTest{
+				Name:    "bad share code",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?code=!!",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"code is not valid"}`,
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -306,6 +488,7 @@ func TestDraw_Open(t *testing.T) {
 					Status: http.StatusOK,
 					Header: http.Header{
 						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"0"`},
 					},
 					Body: `{"deck_id":"1","shuffled":false,"remaining":0,"cards":null}`,
 				},
@@ -336,6 +519,7 @@ func TestDraw_Open(t *testing.T) {
 					Status: http.StatusOK,
 					Header: http.Header{
 						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"0"`},
 					},
 					Body: `{"deck_id":"1","shuffled":true,"remaining":4,"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"JACK","suit":"HEARTS","code":"JH"},{"value":"QUEEN","suit":"HEARTS","code":"QH"},{"value":"KING","suit":"HEARTS","code":"KH"}]}`,
 				},
@@ -366,6 +550,68 @@ func TestDraw_Open(t *testing.T) {
 					Status: http.StatusOK,
 					Header: http.Header{
 						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"0"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":4,"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"JACK","suit":"HEARTS","code":"JH"},{"value":"QUEEN","suit":"HEARTS","code":"QH"},{"value":"KING","suit":"HEARTS","code":"KH"}]}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "not modified",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{"If-None-Match": {`"0"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotModified,
+					Header: http.Header{
+						"Etag": {`"0"`},
+					},
+					Body: ``,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "modified",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{"If-None-Match": {`"3"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"0"`},
 					},
 					Body: `{"deck_id":"1","shuffled":false,"remaining":4,"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"JACK","suit":"HEARTS","code":"JH"},{"value":"QUEEN","suit":"HEARTS","code":"QH"},{"value":"KING","suit":"HEARTS","code":"KH"}]}`,
 				},
@@ -388,6 +634,32 @@ func TestDraw_Open(t *testing.T) {
 	}
 }
 
+func TestDraw_Open_Binary(t *testing.T) {
+	var (
+		d, _, _ = undeck.Deck{ID: "1", System: french.System}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...).Draw(1)
+
+		s = New(memory.NewWith(nil, nil, d), web.StaticIDGetter("1", nil))
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+	)
+
+	r.Header.Set(web.HeaderAccept, web.ContentTypeBinary)
+
+	s.Open(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != web.ContentTypeBinary {
+		t.Fatalf("Open() = %d %s, want %d %s", w.Code, w.Header().Get("Content-Type"), http.StatusOK, web.ContentTypeBinary)
+	}
+
+	var got undeck.Deck
+	if err := got.UnmarshalBinary(w.Body.Bytes()); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	internal.AssertDecksEqual(t, d, got)
+	internal.AssertCardSlicesEqual(t, d.Drawn(), got.Drawn())
+}
+
 func TestDraw_Draw(t *testing.T) {
 	var tests = []test{
 		{
@@ -520,7 +792,7 @@ func TestDraw_Draw(t *testing.T) {
 			},
 			after: memory.NewWith(
 				nil, undeck.OneTwoSwapShuffler,
-				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "JH,QH,KH")...),
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "JH,QH,KH")...),
 			),
 			http: internal.HttpTest{
 				Name:    "non-empty deck draw",
@@ -535,6 +807,7 @@ func TestDraw_Draw(t *testing.T) {
 					Status: http.StatusOK,
 					Header: http.Header{
 						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
 					},
 					Body: `{"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"}]}`,
 				},
@@ -550,7 +823,7 @@ func TestDraw_Draw(t *testing.T) {
 			},
 			after: memory.NewWith(
 				nil, undeck.OneTwoSwapShuffler,
-				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "QH,KH")...),
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "QH,KH")...),
 			),
 			http: internal.HttpTest{
 				Name:    "non-empty deck draw 2",
@@ -565,6 +838,7 @@ func TestDraw_Draw(t *testing.T) {
 					Status: http.StatusOK,
 					Header: http.Header{
 						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
 					},
 					Body: `{"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"JACK","suit":"HEARTS","code":"JH"}]}`,
 				},
@@ -600,19 +874,1963 @@ func TestDraw_Draw(t *testing.T) {
 				},
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.http.Name, func(t *testing.T) {
-			s := &Draw{
-				repo:     tt.fields.repo,
-				idGetter: tt.fields.idGetter,
-			}
-
-			tt.http.Handler = s.Draw
-
-			tt.http.Assert(t)
-			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
-		})
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "stale if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPatch,
+					Header: http.Header{"If-Match": {`"3"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusPreconditionFailed,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck version conflict"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "current if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPatch,
+					Header: http.Header{"If-Match": {`"0"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"cards":[{"value":"ACE","suit":"HEARTS","code":"AH"}]}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Draw
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+func TestDraw_Sort(t *testing.T) {
+	var tests = []test{
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+				),
+				idGetter: web.StaticIDGetter("", web.ErrIDMissing),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "id missing",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"id missing from request"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+				),
+				idGetter: web.StaticIDGetter("2", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "bad by param",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=colour",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"sort order is not valid"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH,KC,2S")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,KC,2C,KH,AH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "new deck order",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":6}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH,KC,2S")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,2C,KC,AH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "suit then rank",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=suit,rank",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":6}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH,KC,2S")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,AH,2S,2C,KC,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "rank then suit",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=rank,suit",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":6}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KC,2S")...).WithDrawn(cards.MustString(french.FromString, "KH,AS,2C")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Version: 1, IsShuffled: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KC,2S")...).WithDrawn(cards.MustString(french.FromString, "AS,2C,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "drawn pile",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=rank,suit&pile=drawn",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","shuffled":true,"remaining":2}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "unknown pile",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?pile=discard",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"pile is not valid"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Hidden: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Hidden: true, Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "KH,AS,2C,AH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "hidden",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "stale if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=rank",
+					Method: http.MethodPost,
+					Header: http.Header{"If-Match": {`"3"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusPreconditionFailed,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck version conflict"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QH,KH")...),
+			),
+			http: internal.HttpTest{
+				Name:    "weak if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?by=rank",
+					Method: http.MethodPost,
+					Header: http.Header{"If-Match": {`W/"0"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusPreconditionFailed,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck version conflict"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Sort
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+
+			// sorting the drawn pile only shows in the drawn cards
+			var got = tt.fields.repo.(*memory.Repo).Dump()
+			for id, d := range tt.after.(*memory.Repo).Dump() {
+				internal.AssertCardSlicesEqual(t, d.Drawn(), got[id].Drawn())
+			}
+		})
+	}
+}
+
+func TestDraw_Stack(t *testing.T) {
+	var tests = []internal.HttpTest{
+		{
+			Name: "position",
+			Request: internal.HttpTestRequest{
+				Path:   "?name=Mnemonica&card=AS",
+				Method: http.MethodGet,
+			},
+			Want: internal.HttpTestWant{
+				Status: http.StatusOK,
+				Header: http.Header{
+					"Content-Type": {web.ContentTypeJSON},
+				},
+				Body: `{"stack":"mnemonica","code":"AS","position":7}`,
+			},
+		},
+		{
+			Name: "unknown stack",
+			Request: internal.HttpTestRequest{
+				Path:   "?name=joker&card=AS",
+				Method: http.MethodGet,
+			},
+			Want: internal.HttpTestWant{
+				Status: http.StatusNotFound,
+				Header: http.Header{
+					"Content-Type": {web.ContentTypeJSON},
+				},
+				Body: `{"error":"stack is not valid"}`,
+			},
+		},
+		{
+			Name: "bad card",
+			Request: internal.HttpTestRequest{
+				Path:   "?name=aronson&card=1S",
+				Method: http.MethodGet,
+			},
+			Want: internal.HttpTestWant{
+				Status: http.StatusBadRequest,
+				Header: http.Header{
+					"Content-Type": {web.ContentTypeJSON},
+				},
+				Body: `{"error":"card does not have a valid rank"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s := New(memory.New(), nil)
+
+			tt.Handler = s.Stack
+			tt.Assert(t)
+		})
+	}
+}
+
+func TestDraw_Code(t *testing.T) {
+	var tests = []test{
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,3S,4S")...),
+				),
+				idGetter: web.StaticIDGetter("2", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,3S,4S")...),
+			),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,3S,4S")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,2S,3S,4S")...),
+			),
+			http: internal.HttpTest{
+				Name:    "existing deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","code":"BA"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,AS")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AS,AS")...),
+			),
+			http: internal.HttpTest{
+				Name:    "duplicate cards",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusUnprocessableEntity,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"cards are not a permutation of the full set"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Code
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+func TestDraw_Odds(t *testing.T) {
+	var tests = []test{
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("2", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?suit=H",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "no matcher",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"odds query is not valid"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "bad draws param",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?suit=H&draws=two",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"strconv.Atoi: parsing \"two\": invalid syntax"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "overdraw",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?suit=H&draws=5",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck does not contain enough cards"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "next card is a heart",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?suit=H",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","remaining":4,"matching":2,"draws":1,"at_least":1,"probability":0.5,"fraction":"1/2"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "both hearts in the next 3",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?suit=H&draws=3&at_least=2",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","remaining":4,"matching":2,"draws":3,"at_least":2,"probability":0.5,"fraction":"1/2"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo: memory.NewWith(
+					nil, undeck.OneTwoSwapShuffler,
+					undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+				),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(
+				nil, undeck.OneTwoSwapShuffler,
+				undeck.Deck{ID: "1", Shuffler: undeck.OneTwoSwapShuffler}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD")...),
+			),
+			http: internal.HttpTest{
+				Name:    "set of codes",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?cards=QS,KD,2C&draws=2",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","remaining":4,"matching":2,"draws":2,"at_least":1,"probability":0.8333333333333334,"fraction":"5/6"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Odds
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+func TestDraw_Summary(t *testing.T) {
+	var played, _, _ = undeck.Deck{ID: "1", IsShuffled: true}.Add(cards.MustString(french.FromString, "AH,JH,QS,KD,2H")...).Draw(2)
+
+	var tests = []test{
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, played),
+				idGetter: web.StaticIDGetter("2", nil),
+			},
+			after: memory.NewWith(nil, nil, played),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, played),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(nil, nil, played),
+			http: internal.HttpTest{
+				Name:    "played deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","shuffled":true,"remaining":3,"suits":{"DIAMONDS":1,"HEARTS":1,"SPADES":1},"ranks":{"KING":1,"QUEEN":1,"TWO":1},"drawn":[{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"JACK","suit":"HEARTS","code":"JH"}]}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Summary
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+func TestDraw_Draw_Concurrent(t *testing.T) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[string]int)
+
+		s = New(
+			memory.NewWith(nil, nil, undeck.Deck{ID: "1"}.Add(french.All()...)),
+			web.StaticIDGetter("1", nil),
+		)
+	)
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var (
+				res drawResponse
+				w   = httptest.NewRecorder()
+				r   = httptest.NewRequest(http.MethodPatch, "/?count=1", nil)
+			)
+
+			s.Draw(w, r)
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Errorf("failed to decode response: %v", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, c := range res.Cards {
+				seen[c.Code]++
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(seen) != 52 {
+		t.Errorf("distinct cards drawn: want = 52, got = %d", len(seen))
+	}
+
+	for code, n := range seen {
+		if n != 1 {
+			t.Errorf("card %s handed out %d times", code, n)
+		}
+	}
+}
+
+func TestDraw_Delete(t *testing.T) {
+	var (
+		d1 = undeck.Deck{ID: "1"}.Add(cards.MustString(french.FromString, "AH,JH")...)
+		d2 = undeck.Deck{ID: "2"}.Add(cards.MustString(french.FromString, "QS,KD")...)
+	)
+
+	var tests = []test{
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, d1, d2),
+				idGetter: web.StaticIDGetter("", web.ErrIDMissing),
+			},
+			after: memory.NewWith(nil, nil, d1, d2),
+			http: internal.HttpTest{
+				Name:    "id missing",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodDelete,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"id missing from request"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, d1, d2),
+				idGetter: web.StaticIDGetter("3", nil),
+			},
+			after: memory.NewWith(nil, nil, d1, d2),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodDelete,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, d1, d2),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(nil, nil, d1, d2),
+			http: internal.HttpTest{
+				Name:    "stale if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodDelete,
+					Header: http.Header{"If-Match": {`"3"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusPreconditionFailed,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck version conflict"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, d1, d2),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(nil, nil, d2),
+			http: internal.HttpTest{
+				Name:    "current if-match",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodDelete,
+					Header: http.Header{"If-Match": {`"0"`}},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"message":"deck deleted"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil, d1, d2),
+				idGetter: web.StaticIDGetter("1", nil),
+			},
+			after: memory.NewWith(nil, nil, d2),
+			http: internal.HttpTest{
+				Name:    "existing deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodDelete,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"message":"deck deleted"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Delete
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+func TestDraw_History(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		h   = history.New(memory.New(memory.WithClock(func() time.Time { return now })))
+
+		expiring = history.New(memory.New(memory.WithTTL(time.Hour, time.Hour), memory.WithClock(func() time.Time { return now })))
+	)
+
+	d, _ := h.Save(ctx, undeck.Deck{ID: "1", System: french.System}.Add(cards.MustString(french.FromString, "AH,KH")...))
+
+	h.Update(ctx, d.ID, func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(1)
+		return d, err
+	})
+
+	h.Save(ctx, undeck.Deck{ID: "3", System: french.System, Hidden: true}.Add(cards.MustString(french.FromString, "AH,KH")...))
+	h.Delete(ctx, "3")
+
+	expiring.Save(ctx, undeck.Deck{ID: "1", System: french.System}.Add(cards.MustString(french.FromString, "AH,KH")...))
+	now = now.Add(2 * time.Hour)
+
+	var tests = []struct {
+		repo     undeck.Repo
+		idGetter web.IDGetter
+		handler  func(s *Draw) http.HandlerFunc
+		http     internal.HttpTest
+	}{
+		{
+			repo:     memory.NewWith(nil, nil, d),
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.History },
+			http: internal.HttpTest{
+				Name:    "history not kept",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotImplemented,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"history of decks is not kept"}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("2", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.History },
+			http: internal.HttpTest{
+				Name:    "unknown deck",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.History },
+			http: internal.HttpTest{
+				Name:    "history",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"deck_id":"1","events":[` +
+						`{"seq":1,"type":"create","version":1,"at":"2021-01-01T00:00:00Z","system":"french","shuffled":false,"cards":["AH","KH"]},` +
+						`{"seq":2,"type":"draw","version":2,"at":"2021-01-01T00:00:00Z","shuffled":false,"cards":["AH"]}]}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Open },
+			http: internal.HttpTest{
+				Name:    "open at an earlier event",
+				Request: internal.HttpTestRequest{Path: "/?at=1", Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":2,"cards":[` +
+						`{"value":"ACE","suit":"HEARTS","code":"AH"},{"value":"KING","suit":"HEARTS","code":"KH"}]}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Open },
+			http: internal.HttpTest{
+				Name:    "open at an unknown event",
+				Request: internal.HttpTestRequest{Path: "/?at=3", Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"event not found"}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("3", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.History },
+			http: internal.HttpTest{
+				Name:    "history of a deleted hidden deck",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("3", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Open },
+			http: internal.HttpTest{
+				Name:    "open a deleted hidden deck at an earlier event",
+				Request: internal.HttpTestRequest{Path: "/?at=1", Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+		{
+			repo:     expiring,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.History },
+			http: internal.HttpTest{
+				Name:    "history of an expired deck",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusGone,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck expired"}`,
+				},
+			},
+		},
+		{
+			repo:     h,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Open },
+			http: internal.HttpTest{
+				Name:    "open at an invalid event",
+				Request: internal.HttpTestRequest{Path: "/?at=first", Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"request is invalid"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.repo,
+				idGetter: tt.idGetter,
+			}
+
+			tt.http.Handler = tt.handler(s)
+
+			tt.http.Assert(t)
+		})
+	}
+}
+
+func TestDraw_Undo(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		u      = undo.New(memory.New(), 0)
+		hidden = undo.New(memory.New(), 0)
+	)
+
+	u.Save(ctx, undeck.Deck{ID: "1"}.Add(cards.MustString(french.FromString, "AH,KH")...))
+	u.Save(ctx, undeck.Deck{ID: "2"}.Add(cards.MustString(french.FromString, "AH,KH")...))
+	u.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(2)
+		return d, err
+	})
+
+	hidden.Save(ctx, undeck.Deck{ID: "1", Hidden: true}.Add(cards.MustString(french.FromString, "AH,KH")...))
+	hidden.Update(ctx, "1", func(d undeck.Deck) (undeck.Deck, error) {
+		d, _, err := d.Draw(1)
+		return d, err
+	})
+
+	var tests = []struct {
+		repo     undeck.Repo
+		idGetter web.IDGetter
+		handler  func(s *Draw) http.HandlerFunc
+		http     internal.HttpTest
+	}{
+		{
+			repo:     memory.NewWith(nil, nil, undeck.Deck{ID: "1"}),
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Undo },
+			http: internal.HttpTest{
+				Name:    "undo not kept",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotImplemented,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"undo is not kept"}`,
+				},
+			},
+		},
+		{
+			repo:     u,
+			idGetter: web.StaticIDGetter("2", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Undo },
+			http: internal.HttpTest{
+				Name:    "nothing to undo",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusConflict,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"nothing to undo"}`,
+				},
+			},
+		},
+		{
+			repo:     u,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Undo },
+			http: internal.HttpTest{
+				Name:    "stale if-match",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{"If-Match": {`"1"`}}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusPreconditionFailed,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck version conflict"}`,
+				},
+			},
+		},
+		{
+			repo:     u,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Undo },
+			http: internal.HttpTest{
+				Name:    "undo a draw",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"3"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":2}`,
+				},
+			},
+		},
+		{
+			repo:     u,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Redo },
+			http: internal.HttpTest{
+				Name:    "redo the draw with a current if-match",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{"If-Match": {`"3"`}}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"4"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":0}`,
+				},
+			},
+		},
+		{
+			repo:     hidden,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Undo },
+			http: internal.HttpTest{
+				Name:    "undo a draw from a hidden deck",
+				Request: internal.HttpTestRequest{Method: http.MethodPost, Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+		{
+			repo:     hidden,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Open },
+			http: internal.HttpTest{
+				Name:    "open a hidden deck",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"2"`},
+					},
+					Body: `{"deck_id":"1","shuffled":false,"remaining":1,"cards":null}`,
+				},
+			},
+		},
+		{
+			repo:     hidden,
+			idGetter: web.StaticIDGetter("1", nil),
+			handler:  func(s *Draw) http.HandlerFunc { return s.Code },
+			http: internal.HttpTest{
+				Name:    "code of a hidden deck",
+				Request: internal.HttpTestRequest{Header: http.Header{}},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.repo,
+				idGetter: tt.idGetter,
+			}
+
+			tt.http.Handler = tt.handler(s)
+
+			tt.http.Assert(t)
+		})
+	}
+}
+
+func TestDraw_Fork(t *testing.T) {
+	var (
+		d, _, _ = undeck.Deck{ID: "a", IsShuffled: true, Version: 3}.Add(cards.MustString(french.FromString, "AH,JH,QS")...).Draw(1)
+		fork    = undeck.Deck{ID: "1", ParentID: "a", IsShuffled: true, Version: 1}.Add(cards.MustString(french.FromString, "JH,QS")...)
+		piles   = fork.WithDrawn(cards.MustString(french.FromString, "AH")...)
+		hidden  = undeck.Deck{ID: "a", Hidden: true}.Add(cards.MustString(french.FromString, "AH,JH,QS")...)
+	)
+
+	var tests = []test{
+		{
+			fields: fields{
+				repo:     memory.NewWith(repo.Sequential(), nil, d),
+				idGetter: web.StaticIDGetter("b", nil),
+			},
+			after: memory.NewWith(nil, nil, d),
+			http: internal.HttpTest{
+				Name:    "non-existent deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusNotFound,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck not found"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(repo.Sequential(), nil, d),
+				idGetter: web.StaticIDGetter("a", nil),
+			},
+			after: memory.NewWith(nil, nil, d, fork),
+			http: internal.HttpTest{
+				Name:    "existing deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","parent_id":"a","shuffled":true,"remaining":2}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(repo.Sequential(), nil, d),
+				idGetter: web.StaticIDGetter("a", nil),
+			},
+			after: memory.NewWith(nil, nil, d, piles),
+			http: internal.HttpTest{
+				Name:    "with piles",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?piles=true",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+						"Etag":         {`"1"`},
+					},
+					Body: `{"deck_id":"1","parent_id":"a","shuffled":true,"remaining":2}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(repo.Sequential(), nil, d),
+				idGetter: web.StaticIDGetter("a", nil),
+			},
+			after: memory.NewWith(nil, nil, d),
+			http: internal.HttpTest{
+				Name:    "invalid piles",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?piles=some",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"strconv.ParseBool: parsing \"some\": invalid syntax"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     memory.NewWith(repo.Sequential(), nil, hidden),
+				idGetter: web.StaticIDGetter("a", nil),
+			},
+			after: memory.NewWith(nil, nil, hidden),
+			http: internal.HttpTest{
+				Name:    "hidden deck",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodPost,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusForbidden,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"deck is hidden"}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.Fork
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+
+			// piles only show in the drawn cards
+			var got = tt.fields.repo.(*memory.Repo).Dump()
+			for id, d := range tt.after.(*memory.Repo).Dump() {
+				internal.AssertCardSlicesEqual(t, d.Drawn(), got[id].Drawn())
+			}
+		})
+	}
+}
+
+func TestDraw_List(t *testing.T) {
+	var (
+		old   = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		d1    = undeck.Deck{ID: "1", CreatedAt: old}.Add(cards.MustString(french.FromString, "AH,JH")...)
+		d2    = undeck.Deck{ID: "2", CreatedAt: old.Add(time.Hour)}
+		d3    = undeck.Deck{ID: "3", CreatedAt: old.Add(2 * time.Hour)}.Add(cards.MustString(french.FromString, "QS")...)
+		decks = memory.NewWith(nil, nil, d1, d2, d3)
+	)
+
+	var tests = []test{
+		{
+			fields: fields{
+				repo:     memory.NewWith(nil, nil),
+				idGetter: nil,
+			},
+			after: memory.NewWith(nil, nil),
+			http: internal.HttpTest{
+				Name:    "empty repo",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[],"next_cursor":""}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "all decks",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[{"deck_id":"1","shuffled":false,"remaining":2},{"deck_id":"2","shuffled":false,"remaining":0},{"deck_id":"3","shuffled":false,"remaining":1}],"next_cursor":""}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "first page",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?limit=2",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[{"deck_id":"1","shuffled":false,"remaining":2},{"deck_id":"2","shuffled":false,"remaining":0}],"next_cursor":"2"}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "next page",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?limit=2&cursor=2",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[{"deck_id":"3","shuffled":false,"remaining":1}],"next_cursor":""}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "exhausted decks",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?max_remaining=0",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[{"deck_id":"2","shuffled":false,"remaining":0}],"next_cursor":""}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "created before",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?created_before=2021-01-01T01:30:00Z&min_remaining=1",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusOK,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"decks":[{"deck_id":"1","shuffled":false,"remaining":2}],"next_cursor":""}`,
+				},
+			},
+		},
+		{
+			fields: fields{
+				repo:     decks,
+				idGetter: nil,
+			},
+			after: decks,
+			http: internal.HttpTest{
+				Name:    "bad created before",
+				Handler: nil,
+				Request: internal.HttpTestRequest{
+					Path:   "?created_before=yesterday",
+					Method: http.MethodGet,
+					Header: http.Header{},
+					Body:   "",
+				},
+				Want: internal.HttpTestWant{
+					Status: http.StatusBadRequest,
+					Header: http.Header{
+						"Content-Type": {web.ContentTypeJSON},
+					},
+					Body: `{"error":"parsing time \"yesterday\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"yesterday\" as \"2006\""}`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.http.Name, func(t *testing.T) {
+			s := &Draw{
+				repo:     tt.fields.repo,
+				idGetter: tt.fields.idGetter,
+			}
+
+			tt.http.Handler = s.List
+
+			tt.http.Assert(t)
+			internal.AssertReposEqual(t, tt.after, tt.fields.repo)
+		})
+	}
+}
+
+// slowRepo takes longer than any request is allowed to, giving up when the context is done
+type slowRepo struct {
+	undeck.Repo
+}
+
+func (r slowRepo) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func (r slowRepo) Find(ctx context.Context, id string) (undeck.Deck, error) {
+	if err := r.wait(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return r.Repo.Find(ctx, id)
+}
+
+func (r slowRepo) Update(ctx context.Context, id string, fn undeck.UpdateFunc) (undeck.Deck, error) {
+	if err := r.wait(ctx); err != nil {
+		return undeck.Deck{}, err
+	}
+
+	return r.Repo.Update(ctx, id, fn)
+}
+
+func TestDraw_Timeout(t *testing.T) {
+	var (
+		inner  = memory.NewWith(nil, nil, undeck.Deck{ID: "1"}.Add(french.All()...))
+		slow   = New(slowRepo{Repo: inner}, web.StaticIDGetter("1", nil))
+		locked = memory.NewWith(nil, nil, undeck.Deck{ID: "1"}.Add(french.All()...))
+		held   = New(locked, web.StaticIDGetter("1", nil))
+
+		holding = make(chan struct{})
+		release = make(chan struct{})
+		done    = make(chan struct{})
+	)
+
+	// a write to the deck holds the lock of the repo until released
+	go func() {
+		defer close(done)
+
+		locked.Update(context.Background(), "1", func(d undeck.Deck) (undeck.Deck, error) {
+			close(holding)
+			<-release
+			return d, nil
+		})
+	}()
+
+	<-holding
+
+	var tests = []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+	}{
+		{name: "open", method: http.MethodGet, handler: slow.Open},
+		{name: "draw", method: http.MethodPatch, handler: slow.Draw},
+		{name: "open while locked", method: http.MethodGet, handler: held.Open},
+		{name: "draw while locked", method: http.MethodPatch, handler: held.Draw},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				w     = httptest.NewRecorder()
+				r     = httptest.NewRequest(tt.method, "/", nil)
+				start = time.Now()
+			)
+
+			web.Timeout(10*time.Millisecond)(tt.handler).ServeHTTP(w, r)
+
+			if w.Code != http.StatusGatewayTimeout {
+				t.Errorf("status = %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body.String())
+			}
+
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("request took %v, want it abandoned at the deadline", elapsed)
+			}
+		})
+	}
+
+	close(release)
+	<-done
+
+	for _, r := range []undeck.Repo{inner, locked} {
+		if d, _ := r.Find(context.Background(), "1"); d.Remaining() != 52 {
+			t.Errorf("deck changed by timed out requests: %d cards remaining, want 52", d.Remaining())
+		}
+	}
+}
+
+func TestDraw_Tenants(t *testing.T) {
+	var (
+		id    string
+		keys  = web.Tenants(web.APIKeyTenantGetter(map[string]string{"k1": "acme", "k2": "umbra"}))
+		inner = memory.New()
+		s     = New(tenant.New(inner, tenant.WithQuota(1)), func(r *http.Request) (string, error) { return id, nil })
+	)
+
+	var tests = []struct {
+		name    string
+		key     string
+		method  string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{name: "create", key: "k1", method: http.MethodPost, handler: s.Create, want: http.StatusOK},
+		{name: "open", key: "k1", method: http.MethodGet, handler: s.Open, want: http.StatusOK},
+		{name: "over quota", key: "k1", method: http.MethodPost, handler: s.Create, want: http.StatusTooManyRequests},
+		{name: "open in another tenant", key: "k2", method: http.MethodGet, handler: s.Open, want: http.StatusNotFound},
+		{name: "delete in another tenant", key: "k2", method: http.MethodDelete, handler: s.Delete, want: http.StatusNotFound},
+		{name: "create in another tenant", key: "k2", method: http.MethodPost, handler: s.Create, want: http.StatusOK},
+		{name: "unknown key", key: "k3", method: http.MethodGet, handler: s.Open, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(tt.method, "/", nil)
+			)
+
+			r.Header.Set(web.HeaderAPIKey, tt.key)
+			keys(tt.handler).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+
+			var res createResponse
+			if json.Unmarshal(w.Body.Bytes(), &res) == nil && res.DeckID != "" && id == "" {
+				id = res.DeckID
+			}
+		})
+	}
+
+	if page, _ := inner.List(context.Background(), undeck.ListFilter{}); len(page.Decks) != 2 {
+		t.Errorf("%d decks stored, want one per tenant", len(page.Decks))
 	}
 }
@@ -1,15 +1,19 @@
 package web
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,11 +22,37 @@ const (
 
 	// ContentTypeHTML is the content type for HTML
 	ContentTypeHTML = "text/html"
+
+	// ContentTypeBinary is the content type for binary encoded resources
+	ContentTypeBinary = "application/octet-stream"
+)
+
+const (
+	// HeaderAccept is the request header listing the content types the client wants
+	HeaderAccept = "Accept"
+
+	// HeaderETag is the response header holding the version of a resource
+	HeaderETag = "ETag"
+
+	// HeaderIfMatch is the request header to only proceed if the resource is at a given version
+	HeaderIfMatch = "If-Match"
+
+	// HeaderIfNoneMatch is the request header to only proceed if the resource is not at a given version
+	HeaderIfNoneMatch = "If-None-Match"
+
+	// HeaderAPIKey is the request header holding the key a client was given to reach the decks of its tenant
+	HeaderAPIKey = "X-API-Key"
+
+	// HeaderTenant is the request header naming the tenant of a request, set by a trusted proxy
+	HeaderTenant = "X-Tenant"
 )
 
 // IDGetter gets id from a request
 type IDGetter func(r *http.Request) (string, error)
 
+// TenantGetter gets the tenant a request is made for
+type TenantGetter func(r *http.Request) (string, error)
+
 var (
 	// ErrInvalidRequest means a request is either nil or not appropriate for the requested action
 	ErrInvalidRequest = errors.New("request is invalid")
@@ -32,6 +62,9 @@ var (
 
 	// ErrIDMissing from query string
 	ErrIDMissing = errors.New("id missing from request")
+
+	// ErrTenantUnknown means a request has no tenant or an api key which is not known
+	ErrTenantUnknown = errors.New("tenant missing or unknown")
 )
 
 // Print sends data to the browser
@@ -65,6 +98,84 @@ func JsonError(w http.ResponseWriter, status int, err error) {
 	Print(w, status, ContentTypeJSON, []byte(`{"error":"`+m+`"}`))
 }
 
+// Accepts reports whether the request lists ctype in its Accept header, wildcards are not taken into account
+// so that clients explicitly ask for anything other than JSON
+func Accepts(r *http.Request, ctype string) bool {
+	for _, v := range r.Header.Values(HeaderAccept) {
+		for _, t := range strings.Split(v, ",") {
+			if i := strings.IndexByte(t, ';'); i != -1 {
+				t = t[:i]
+			}
+
+			if strings.EqualFold(strings.TrimSpace(t), ctype) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Timeout gives requests to next a deadline of d, zero for none. Handlers pass the request context on so that
+// whatever they wait for gives up once the deadline passes
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ctx, cancel = context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Tenants runs next in the tenant of each request, see repo.WithTenant. Requests without a tenant get
+// 401 Unauthorized and requests with a tenant which is not valid get 400 Bad Request
+func Tenants(get TenantGetter) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var t, err = get(r)
+			if err != nil {
+				JsonError(w, http.StatusUnauthorized, err)
+				return
+			}
+
+			if err = repo.ValidTenant(t); err != nil {
+				JsonError(w, http.StatusBadRequest, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(repo.WithTenant(r.Context(), t)))
+		})
+	}
+}
+
+// APIKeyTenantGetter gets the tenant of the api key of a request from keys, mapping each key to its tenant
+func APIKeyTenantGetter(keys map[string]string) TenantGetter {
+	return func(r *http.Request) (string, error) {
+		var t, ok = keys[r.Header.Get(HeaderAPIKey)]
+		if !ok {
+			return "", ErrTenantUnknown
+		}
+
+		return t, nil
+	}
+}
+
+// HeaderTenantGetter gets the tenant of a request from its HeaderTenant header, which must not be settable by clients
+func HeaderTenantGetter(r *http.Request) (string, error) {
+	var t = r.Header.Get(HeaderTenant)
+	if t == "" {
+		return "", ErrTenantUnknown
+	}
+
+	return t, nil
+}
+
 // VerifyBody payload
 func VerifyBody(b []byte, sig, key string) error {
 	if len(sig) != 45 || !strings.HasPrefix(sig, "sha1=") {
@@ -129,3 +240,36 @@ func StaticIDGetter(id string, err error) IDGetter {
 		return id, err
 	}
 }
+
+// ETag for a version of a resource
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// MatchETag reports whether an If-Match header value matches an etag. The comparison is strong: a weak tag never
+// matches, see RFC 9110 section 13.1.1
+func MatchETag(header, etag string) bool {
+	return matchETag(header, etag, false)
+}
+
+// MatchWeakETag reports whether an If-None-Match header value matches an etag, weak tags match their strong
+// counterpart, see RFC 9110 section 13.1.2
+func MatchWeakETag(header, etag string) bool {
+	return matchETag(header, etag, true)
+}
+
+func matchETag(header, etag string, weak bool) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+
+		if weak {
+			v = strings.TrimPrefix(v, "W/")
+		}
+
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"errors"
 	"go.fluxy.net/undeck/internal"
+	"go.fluxy.net/undeck/repo"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPrint(t *testing.T) {
@@ -408,3 +410,132 @@ func TestVerifyBody(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		header   string
+		etag     string
+		want     bool
+		wantWeak bool
+	}{
+		{header: `"1"`, etag: ETag(1), want: true, wantWeak: true},
+		{header: `"2"`, etag: ETag(1), want: false, wantWeak: false},
+		{header: `W/"1"`, etag: ETag(1), want: false, wantWeak: true},
+		{header: `"0", "1"`, etag: ETag(1), want: true, wantWeak: true},
+		{header: `W/"0", W/"1"`, etag: ETag(1), want: false, wantWeak: true},
+		{header: `*`, etag: ETag(7), want: true, wantWeak: true},
+		{header: `1`, etag: ETag(1), want: false, wantWeak: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := MatchETag(tt.header, tt.etag); got != tt.want {
+				t.Errorf("MatchETag() = %t, want %t", got, tt.want)
+			}
+
+			if got := MatchWeakETag(tt.header, tt.etag); got != tt.wantWeak {
+				t.Errorf("MatchWeakETag() = %t, want %t", got, tt.wantWeak)
+			}
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: ContentTypeBinary, want: true},
+		{header: "application/json, application/octet-stream;q=0.5", want: true},
+		{header: "*/*", want: false},
+		{header: ContentTypeJSON, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			var r = httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(HeaderAccept, tt.header)
+
+			if got := Accepts(r, ContentTypeBinary); got != tt.want {
+				t.Errorf("Accepts() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		want    bool
+	}{
+		{name: "none", timeout: 0, want: false},
+		{name: "second", timeout: time.Second, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got bool
+				h   = Timeout(tt.timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, got = r.Context().Deadline()
+				}))
+			)
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got != tt.want {
+				t.Errorf("Timeout() deadline set = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenants(t *testing.T) {
+	var keys = APIKeyTenantGetter(map[string]string{"k1": "acme", "k2": "acme:1"})
+
+	tests := []struct {
+		name       string
+		get        TenantGetter
+		header     string
+		value      string
+		wantStatus int
+		want       string
+	}{
+		{name: "api key", get: keys, header: HeaderAPIKey, value: "k1", wantStatus: http.StatusOK, want: "acme"},
+		{name: "api key unknown", get: keys, header: HeaderAPIKey, value: "k3", wantStatus: http.StatusUnauthorized},
+		{name: "api key missing", get: keys, wantStatus: http.StatusUnauthorized},
+		{name: "api key invalid tenant", get: keys, header: HeaderAPIKey, value: "k2", wantStatus: http.StatusBadRequest},
+		{name: "header", get: HeaderTenantGetter, header: HeaderTenant, value: "umbra", wantStatus: http.StatusOK, want: "umbra"},
+		{name: "header missing", get: HeaderTenantGetter, wantStatus: http.StatusUnauthorized},
+		{name: "header invalid", get: HeaderTenantGetter, header: HeaderTenant, value: "um bra", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got string
+				w   = httptest.NewRecorder()
+				r   = httptest.NewRequest(http.MethodGet, "/", nil)
+				h   = Tenants(tt.get)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = repo.Tenant(r.Context())
+				}))
+			)
+
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Tenants() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			if got != tt.want {
+				t.Errorf("Tenants() tenant = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}